@@ -0,0 +1,67 @@
+package ldifparser
+
+import (
+	"sort"
+	"strings"
+)
+
+// AttributeOrder sorts attribute names, in place, into the order
+// they should be written in. The DN is always written first and
+// is never passed to an AttributeOrder. Entities don't remember
+// the order of their attributes, so records read with
+// ReadRecordsChanneled should be written with WriteRecord to
+// keep the order of the source.
+type AttributeOrder func(names []string)
+
+func lessFold(a, b string) bool {
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// AlphabeticalOrder sorts attribute names case-insensitively.
+func AlphabeticalOrder(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return lessFold(names[i], names[j])
+	})
+}
+
+// ObjectClassFirstOrder writes objectClass before
+// all other attributes, which are sorted alphabetically.
+func ObjectClassFirstOrder(names []string) {
+	SchemaOrder("objectClass")(names)
+}
+
+// SchemaOrder writes the attributes listed in `schema` first, in the
+// order they are listed, followed by all other attributes sorted
+// alphabetically. Attribute names are compared case-insensitively.
+func SchemaOrder(schema ...string) AttributeOrder {
+	rank := make(map[string]int, len(schema))
+	for i, name := range schema {
+		rank[strings.ToLower(name)] = i
+	}
+
+	return func(names []string) {
+		sort.SliceStable(names, func(i, j int) bool {
+			iRank, iFound := rank[strings.ToLower(names[i])]
+			jRank, jFound := rank[strings.ToLower(names[j])]
+
+			switch {
+			case iFound && jFound:
+				return iRank < jRank
+			case iFound != jFound:
+				return iFound
+			default:
+				return lessFold(names[i], names[j])
+			}
+		})
+	}
+}
+
+// ComparatorOrder sorts attribute names with a caller-supplied
+// comparator that returns true if `a` should be written before `b`.
+func ComparatorOrder(less func(a, b string) bool) AttributeOrder {
+	return func(names []string) {
+		sort.SliceStable(names, func(i, j int) bool {
+			return less(names[i], names[j])
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/kgoins/ldifparser/entitybuilder"
+	"github.com/kgoins/ldifparser/syntax"
+)
+
+// generateLdif builds an LDIF file of user entities that each
+// carry a binary thumbnailPhoto of `blobSize` bytes.
+func generateLdif(numEntities int, blobSize int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	blob := make([]byte, blobSize)
+	rng.Read(blob)
+	encodedBlob := base64.StdEncoding.EncodeToString(blob)
+
+	var ldif bytes.Buffer
+	for i := 0; i < numEntities; i++ {
+		fmt.Fprintf(&ldif, "# user%d, Users, contoso.com\n", i)
+		fmt.Fprintf(&ldif, "dn: CN=user%d,OU=Users,DC=contoso,DC=com\n", i)
+		fmt.Fprintf(&ldif, "objectClass: top\nobjectClass: person\nobjectClass: user\n")
+		fmt.Fprintf(&ldif, "cn: user%d\nsAMAccountName: user%d\n", i, i)
+		fmt.Fprintf(&ldif, "memberOf: CN=Domain Users,CN=Users,DC=contoso,DC=com\n")
+		fmt.Fprintf(&ldif, "memberOf: CN=Group%d,OU=Groups,DC=contoso,DC=com\n", i%10)
+		fmt.Fprintf(&ldif, "objectGUID:: 7OBfD10nQkSVYY8UHCV2aQ==\n")
+		fmt.Fprintf(&ldif, "thumbnailPhoto:: %s\n", encodedBlob)
+		fmt.Fprintf(&ldif, "userCertificate:: %s\n", encodedBlob)
+		fmt.Fprintf(&ldif, "whenCreated: 20120423175240.0Z\n\n")
+	}
+
+	return ldif.Bytes()
+}
+
+func benchmarkReadEntities(b *testing.B, ldif []byte, conf ldifparser.ReaderConf) {
+	b.SetBytes(int64(len(ldif)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader := ldifparser.NewLdifReader(bytes.NewReader(ldif), conf)
+		for _, resp := range reader.ReadEntities() {
+			if resp.Error != nil {
+				b.Fatal(resp.Error)
+			}
+		}
+	}
+}
+
+func BenchmarkReader_LargeBlobs_NoFilter(b *testing.B) {
+	ldif := generateLdif(100, 128*1024)
+	benchmarkReadEntities(b, ldif, ldifparser.NewReaderConf())
+}
+
+func BenchmarkReader_LargeBlobs_IncludeFilter(b *testing.B) {
+	ldif := generateLdif(100, 128*1024)
+
+	conf := ldifparser.NewReaderConf()
+	conf.AttributeFilter = entitybuilder.NewAttributeFilter("sAMAccountName", "memberOf")
+	benchmarkReadEntities(b, ldif, conf)
+}
+
+// foldLdif folds every line of `ldif` at `width` bytes.
+func foldLdif(ldif []byte, width int) []byte {
+	var folded bytes.Buffer
+	for _, line := range strings.Split(string(ldif), "\n") {
+		for _, foldedLine := range syntax.FoldLine(line, width) {
+			folded.WriteString(foldedLine + "\n")
+		}
+	}
+
+	return folded.Bytes()
+}
+
+func readTestFile(b *testing.B, fileName string) []byte {
+	ldif, err := ioutil.ReadFile(filepath.Join("testdata", fileName))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return ldif
+}
+
+func BenchmarkReader_TestUsers(b *testing.B) {
+	ldif := readTestFile(b, testFileName)
+	benchmarkReadEntities(b, ldif, ldifparser.NewReaderConf())
+}
+
+func BenchmarkReader_HugeAttr(b *testing.B) {
+	ldif := readTestFile(b, "hugeattr.ldif")
+
+	conf := ldifparser.NewReaderConf()
+	conf.ScannerBufferSize = 1400000
+	benchmarkReadEntities(b, ldif, conf)
+}
+
+func BenchmarkReader_SmallEntities(b *testing.B) {
+	ldif := generateLdif(10000, 16)
+	benchmarkReadEntities(b, ldif, ldifparser.NewReaderConf())
+}
+
+func BenchmarkReader_Folded(b *testing.B) {
+	ldif := foldLdif(generateLdif(100, 16*1024), 76)
+	benchmarkReadEntities(b, ldif, ldifparser.NewReaderConf())
+}
+
+func BenchmarkReadEntity_LastEntity(b *testing.B) {
+	ldif := generateLdif(10000, 16)
+
+	b.SetBytes(int64(len(ldif)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader := ldifparser.NewLdifReader(bytes.NewReader(ldif))
+		e, err := reader.ReadEntity("sAMAccountName", "user9999")
+		if err != nil || e.IsEmpty() {
+			b.Fatal("entity not found", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+// readerAtOnly hides the Size method of the wrapped
+// reader so that the input has to be measured
+type readerAtOnly struct {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// These tests are meant to be run with the race detector: go test -race
+func TestConcurrency_ReadEntity(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+	names := []string{"MYUSR", "DISABLEDUSER", "NOSUCHUSER"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			e, err := ldifReader.ReadEntity("sAMAccountName", name)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			eName, _ := e.GetSingleValuedAttribute("sAMAccountName")
+			if name != "NOSUCHUSER" && eName != name {
+				errs <- fmt.Errorf("expected %s, found %q", name, eName)
+			}
+		}(names[i%len(names)])
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+}
+
+func TestConcurrency_ReadEntitiesAndLookups(t *testing.T) {
+	r := require.New(t)
+
+	ldif := generateLdif(200, 256)
+	input := readerAtOnly{bytes.NewReader(ldif), bytes.NewReader(ldif)}
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 2
+	ldifReader := ldifparser.NewLdifReader(input, conf)
+
+	var wg sync.WaitGroup
+	counts := make(chan int, 4)
+	errs := make(chan error, 4)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			counts <- len(ldifReader.ReadEntities())
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+			e, err := ldifReader.ReadEntity("cn", fmt.Sprintf("user%d", i*50))
+			if err == nil && e.IsEmpty() {
+				err = fmt.Errorf("user%d not found", i*50)
+			}
+			errs <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(counts)
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+
+	for count := range counts {
+		r.Equal(200, count)
+	}
+}
+
+func TestConcurrency_ReadEntitiesPage(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(generateLdif(500, 16)))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+
+			page, err := ldifReader.ReadEntitiesPage(offset, 10)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			want := fmt.Sprintf("CN=user%d,OU=Users,DC=contoso,DC=com", offset)
+			if dn, _ := page.Entities[0].Entity.GetDN(); dn != want {
+				errs <- fmt.Errorf("expected %s, found %q", want, dn)
+			}
+		}(i * 25)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		r.NoError(err)
+	}
+}
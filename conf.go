@@ -1,38 +1,139 @@
 package ldifparser
 
 import (
+	"time"
+
+	"github.com/kgoins/ldapentity/entity"
 	"github.com/kgoins/ldifparser/entitybuilder"
 	"github.com/kgoins/ldifparser/internal"
+	"github.com/kgoins/ldifparser/syntax"
 )
 
 const LDAPMaxLineSize int = 1000000
 
+// DefaultPollInterval is how long FollowEntities waits
+// before checking a followed input for more data.
+const DefaultPollInterval time.Duration = 500 * time.Millisecond
+
+// DefaultWriterBufferSize is the size of the buffer
+// placed in front of an LdifWriter's output.
+const DefaultWriterBufferSize int = 64 * 1024
+
 type ReaderConf struct {
 	Logger            internal.ILogger
 	AttributeFilter   entitybuilder.AttributeFilter
 	ScannerBufferSize int
 	ContinueOnErr     bool
+
+	// Parallelism is the number of workers that parse entities
+	// when streaming. Values less than two parse sequentially.
+	Parallelism int
+
+	// PreserveOrder emits entities in file order when parsing in
+	// parallel. Otherwise entities are emitted as they are parsed.
+	PreserveOrder bool
+
+	// ZeroCopy builds entities whose values refer to the memory of an
+	// MmapFile input instead of copies of it. These entities must not
+	// be used after the file is closed. Other inputs are unaffected.
+	ZeroCopy bool
+
+	// ErrOnAmbiguousKey makes ReadEntity return ErrAmbiguousKey
+	// instead of the first match when more than one entity
+	// matches the key. This requires scanning the whole input.
+	ErrOnAmbiguousKey bool
+
+	// KeyMatchRule determines how lookup keys are compared with
+	// attribute values. The default ignores case and extra spaces.
+	KeyMatchRule KeyMatchRule
+
+	// SourceName identifies the input in the metadata of each
+	// entity, such as the name of the file being read.
+	SourceName string
+
+	// ResumeFrom starts streaming reads, such as ReadEntitiesChanneled,
+	// at the position of a ResumeToken instead of the start of the input.
+	// Key lookups, CountEntities and ReadEntitiesPage always cover the
+	// whole input.
+	ResumeFrom ResumeToken
+
+	// PollInterval is how long FollowEntities waits before checking
+	// for more input. Values less than one use `DefaultPollInterval`.
+	PollInterval time.Duration
 }
 
 // NewReaderConf constructs a ReaderConf that has logging
-// disabled and a scan buffer size of `LDAPMaxLineSize`
+// disabled, a scan buffer size of `LDAPMaxLineSize`
+// and sequential parsing
 func NewReaderConf() ReaderConf {
 	return ReaderConf{
 		Logger:            internal.NewNopLogger(),
 		AttributeFilter:   entitybuilder.NewAttributeFilter(),
 		ScannerBufferSize: LDAPMaxLineSize,
 		ContinueOnErr:     true,
+		Parallelism:       1,
+		PreserveOrder:     true,
+		ZeroCopy:          false,
+		ErrOnAmbiguousKey: false,
+		KeyMatchRule:      KeyMatchCaseIgnore,
+		PollInterval:      DefaultPollInterval,
 	}
 }
 
+// TitleFormatter builds the title comment line written before an entity.
+// Lines that do not start with `#` will be prefixed with `# `.
+type TitleFormatter func(e entity.Entity) (string, error)
+
 type WriterConf struct {
-	Logger         internal.ILogger
+	Logger internal.ILogger
+
+	// OmitTitles stops the title comment from
+	// being written before each entity
+	OmitTitles bool
+
+	// TitleFormatter builds the title comments unless OmitTitles
+	// is set. Nil uses the ldapsearch format of syntax.BuildTitleLine.
+	TitleFormatter TitleFormatter
+
+	// SortAttributes is kept for compatibility. Attributes are
+	// always written alphabetically when no AttributeOrder is set.
 	SortAttributes bool
+
+	// AttributeOrder determines the order in which attributes are
+	// written after the DN. Nil writes them alphabetically.
+	AttributeOrder AttributeOrder
+
+	// SortValues writes the values of multi-valued
+	// attributes in alphabetical order.
+	SortValues bool
+
+	// PreserveUTF8 writes non-ASCII UTF-8 values as plain text
+	// instead of base64 encoding them as RFC 2849 requires.
+	PreserveUTF8 bool
+
+	// FoldWidth is the maximum line length before a line is folded
+	// onto RFC 2849 continuation lines. Zero disables folding.
+	FoldWidth int
+
+	// BufferSize is the size of the output buffer. Values less
+	// than one use `DefaultWriterBufferSize`.
+	BufferSize int
 }
 
+// NewWriterConf constructs a WriterConf that has logging disabled,
+// writes ldapsearch style title comments, does not fold long lines,
+// buffers `DefaultWriterBufferSize` bytes, and base64 encodes all
+// values that are not RFC 2849 SAFE-STRINGs
 func NewWriterConf() WriterConf {
 	return WriterConf{
 		Logger:         internal.NewNopLogger(),
+		OmitTitles:     false,
+		TitleFormatter: syntax.BuildTitleLine,
 		SortAttributes: false,
+		AttributeOrder: nil,
+		SortValues:     false,
+		PreserveUTF8:   false,
+		FoldWidth:      0,
+		BufferSize:     DefaultWriterBufferSize,
 	}
 }
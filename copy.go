@@ -0,0 +1,177 @@
+package ldifparser
+
+import (
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/kgoins/ldapentity/entity"
+)
+
+// ErrorPolicy determines how WriteEntities handles entities
+// that were read, transformed or serialized with an error.
+type ErrorPolicy int
+
+const (
+	// SkipOnError drops the failed entity and continues
+	SkipOnError ErrorPolicy = iota
+	// AbortOnError stops writing and returns the error
+	AbortOnError
+	// CommentOnError writes the error as an LDIF comment and continues
+	CommentOnError
+)
+
+// EntityTransform modifies an entity before it is written.
+// Returning false as the second value drops the entity.
+type EntityTransform func(e entity.Entity) (entity.Entity, bool, error)
+
+// CopyOptions configure how WriteEntities processes its input.
+type CopyOptions struct {
+	// Transform is applied to every entity before it is written.
+	// A nil Transform writes entities unchanged.
+	Transform   EntityTransform
+	ErrorPolicy ErrorPolicy
+}
+
+// CopySummary counts the entities processed by WriteEntities.
+type CopySummary struct {
+	Read    int
+	Written int
+	Dropped int
+	Errors  int
+}
+
+// writeErrorComment writes an entity error as a comment block
+func (w *LdifWriter) writeErrorComment(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		w.writeLine("# error: " + line)
+	}
+	w.write("\n")
+}
+
+// handleEntityError applies the error policy to a failed entity and
+// returns the error that should stop writing, if any.
+func (w *LdifWriter) handleEntityError(err error, policy ErrorPolicy, summary *CopySummary) error {
+	summary.Errors++
+
+	switch policy {
+	case AbortOnError:
+		return err
+	case CommentOnError:
+		w.writeErrorComment(err)
+	}
+
+	return w.err
+}
+
+// WriteEntities writes every entity received from `entities` until the
+// channel is closed, applying the optional transform and handling failed
+// entities according to the error policy. Errors from the underlying
+// io.Writer always stop writing. The returned summary counts the entities
+// processed before writing stopped.
+func (w *LdifWriter) WriteEntities(entities <-chan EntityResp, opts ...CopyOptions) (summary CopySummary, err error) {
+	var actualOpts CopyOptions
+	if len(opts) > 0 {
+		actualOpts = opts[0]
+	}
+
+	for resp := range entities {
+		summary.Read++
+
+		if w.err != nil {
+			return summary, w.err
+		}
+
+		e := resp.Entity
+		if resp.Error != nil {
+			err = w.handleEntityError(resp.Error, actualOpts.ErrorPolicy, &summary)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		if actualOpts.Transform != nil {
+			keep := true
+			e, keep, err = actualOpts.Transform(e)
+
+			if err != nil {
+				dn, _ := resp.Entity.GetDN()
+				err = merry.Prepend(err, "failed to transform entity "+dn)
+
+				err = w.handleEntityError(err, actualOpts.ErrorPolicy, &summary)
+				if err != nil {
+					return
+				}
+				continue
+			}
+
+			if !keep {
+				summary.Dropped++
+				continue
+			}
+		}
+
+		err = w.WriteEntity(e)
+		if err != nil {
+			if w.err != nil {
+				return summary, w.err
+			}
+
+			err = w.handleEntityError(err, actualOpts.ErrorPolicy, &summary)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		summary.Written++
+	}
+
+	return summary, w.err
+}
+
+// CopyEntities streams every entity read by `r` into `w` with WriteEntities.
+// The writer is not flushed, so callers should call Flush or Close afterwards.
+func CopyEntities(r LdifReader, w *LdifWriter, opts ...CopyOptions) (CopySummary, error) {
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	entities := r.ReadEntitiesChanneled(interrupt)
+	return w.WriteEntities(entities, opts...)
+}
+
+// CopyRawBlocks writes the raw text of every entity record read by `r`
+// that `keep` matches into `w`, preserving the input's formatting. A nil
+// `keep` copies every record. Errors are handled according to `policy`,
+// and records that are not kept are counted as dropped. The writer is
+// not flushed.
+func CopyRawBlocks(r LdifReader, w *LdifWriter, keep RawPredicate, policy ErrorPolicy) (summary CopySummary, err error) {
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	for resp := range r.ReadRawBlocksChanneled(nil, interrupt) {
+		summary.Read++
+
+		if resp.Error != nil {
+			err = w.handleEntityError(resp.Error, policy, &summary)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		if keep != nil && !keep(resp.Block) {
+			summary.Dropped++
+			continue
+		}
+
+		err = w.WriteRaw(resp.Block)
+		if err != nil {
+			return
+		}
+
+		summary.Written++
+	}
+
+	return summary, w.err
+}
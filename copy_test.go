@@ -0,0 +1,123 @@
+package ldifparser_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestReader(t *testing.T, fileName string) ldifparser.LdifReader {
+	testFile, err := os.Open(filepath.Join(getTestDataDir(), fileName))
+	require.NoError(t, err)
+	t.Cleanup(func() { testFile.Close() })
+
+	return ldifparser.NewLdifReader(testFile)
+}
+
+func TestCopy_CopyEntities(t *testing.T) {
+	r := require.New(t)
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	summary, err := ldifparser.CopyEntities(openTestReader(t, testFileName), writer)
+	r.NoError(err)
+	r.NoError(writer.Flush())
+
+	r.Equal(ldifparser.CopySummary{
+		Read:    numTestFileEntities,
+		Written: numTestFileEntities,
+	}, summary)
+
+	// binary values must be written back in their base64 form
+	out := outBuffer.String()
+	r.Equal(numTestFileEntities, strings.Count(out, "objectGUID:: 7OBfD10nQkSVYY8UHCV2aQ==\n"))
+	r.Equal(numTestFileEntities, strings.Count(out, "objectSid:: AQUAAAAAAAUVAAAAa9ZiBBbA6jKDPStVYiIMAA==\n"))
+
+	reader := ldifparser.NewLdifReader(strings.NewReader(out))
+	r.Len(reader.ReadEntities(), numTestFileEntities)
+}
+
+func TestCopy_Transform(t *testing.T) {
+	r := require.New(t)
+
+	opts := ldifparser.CopyOptions{
+		Transform: func(e entity.Entity) (entity.Entity, bool, error) {
+			name, _ := e.GetSingleValuedAttribute("sAMAccountName")
+			if name == "MYPC" {
+				return e, false, nil
+			}
+
+			out := entity.NewEntity("cn=" + name)
+			out.AddAttribute(entity.NewEntityAttribute("cn", name))
+			return out, true, nil
+		},
+	}
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	summary, err := ldifparser.CopyEntities(openTestReader(t, testFileName), writer, opts)
+	r.NoError(err)
+	r.NoError(writer.Flush())
+
+	r.Equal(ldifparser.CopySummary{Read: 3, Written: 2, Dropped: 1}, summary)
+	r.NotContains(outBuffer.String(), "MYPC")
+	r.Contains(outBuffer.String(), "dn: cn=DISABLEDUSER\ncn: DISABLEDUSER\n")
+}
+
+func TestCopy_ErrorPolicies(t *testing.T) {
+	r := require.New(t)
+
+	testMap := map[ldifparser.ErrorPolicy]ldifparser.CopySummary{
+		ldifparser.SkipOnError:    {Read: 3, Written: 2, Errors: 1},
+		ldifparser.CommentOnError: {Read: 3, Written: 2, Errors: 1},
+		ldifparser.AbortOnError:   {Read: 2, Written: 1, Errors: 1},
+	}
+
+	for policy, expected := range testMap {
+		var outBuffer strings.Builder
+		writer := ldifparser.NewLdifWriter(&outBuffer)
+
+		opts := ldifparser.CopyOptions{ErrorPolicy: policy}
+		reader := openTestReader(t, "test_users_with_err.ldif")
+
+		summary, err := ldifparser.CopyEntities(reader, writer, opts)
+		r.NoError(writer.Flush())
+		r.Equal(expected, summary)
+
+		if policy == ldifparser.AbortOnError {
+			r.Error(err)
+		} else {
+			r.NoError(err)
+		}
+
+		hasComment := strings.Contains(outBuffer.String(), "# error: ")
+		r.Equal(policy == ldifparser.CommentOnError, hasComment)
+	}
+}
+
+func TestCopy_TransformError(t *testing.T) {
+	r := require.New(t)
+
+	opts := ldifparser.CopyOptions{
+		ErrorPolicy: ldifparser.AbortOnError,
+		Transform: func(e entity.Entity) (entity.Entity, bool, error) {
+			return e, true, errors.New("bad entity")
+		},
+	}
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	summary, err := ldifparser.CopyEntities(openTestReader(t, testFileName), writer, opts)
+	r.Error(err)
+	r.Contains(err.Error(), "CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com")
+	r.Equal(ldifparser.CopySummary{Read: 1, Errors: 1}, summary)
+}
@@ -1,6 +1,8 @@
 package entitybuilder
 
 import (
+	"path"
+	"regexp"
 	"strings"
 
 	hashset "github.com/kgoins/hashset/pkg"
@@ -45,3 +47,194 @@ func NewAttributeFilter(filterParts ...string) AttributeFilter {
 	filter.Add(filterParts...)
 	return &filter
 }
+
+// operationalAttributes are the attributes selected by `+` and left out
+// by `*`. They cover the common RFC 4512, OpenLDAP and Active Directory
+// operational and constructed attributes.
+var operationalAttributes = []string{
+	"createTimestamp", "modifyTimestamp", "creatorsName", "modifiersName",
+	"subschemaSubentry", "structuralObjectClass", "hasSubordinates",
+	"entryDN", "entryUUID", "entryCSN", "contextCSN", "entryTtl",
+	"pwdChangedTime", "pwdAccountLockedTime", "pwdFailureTime",
+	"pwdHistory", "pwdGraceUseTime", "pwdReset", "pwdPolicySubentry",
+	"allowedAttributes", "allowedAttributesEffective", "allowedChildClasses",
+	"allowedChildClassesEffective", "canonicalName", "fromEntry",
+	"msDS-Approx-Immed-Subordinates", "msDS-KeyVersionNumber",
+	"msDS-PrincipalName", "msDS-ReplAttributeMetaData",
+	"msDS-ReplValueMetaData", "msDS-User-Account-Control-Computed",
+	"msDS-UserPasswordExpiryTimeComputed", "parentGUID",
+	"primaryGroupToken", "sDRightsEffective", "tokenGroups",
+	"tokenGroupsGlobalAndUniversal", "tokenGroupsNoGCAcceptable",
+}
+
+// DefaultOperationalAttributes returns the attribute names that
+// RuleAttrFilters treat as operational unless configured otherwise.
+func DefaultOperationalAttributes() []string {
+	names := make([]string, len(operationalAttributes))
+	copy(names, operationalAttributes)
+	return names
+}
+
+// attrRules is a set of attribute names, glob patterns and regexes
+type attrRules struct {
+	names     hashset.StrHashset
+	globs     []string
+	regexes   []*regexp.Regexp
+	allUser   bool
+	allOpAttr bool
+}
+
+func newAttrRules() attrRules {
+	return attrRules{names: hashset.NewStrHashset()}
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+func (rules *attrRules) add(patterns ...string) {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		switch {
+		case pattern == "*":
+			rules.allUser = true
+		case pattern == "+":
+			rules.allOpAttr = true
+		case isGlob(pattern):
+			rules.globs = append(rules.globs, pattern)
+		default:
+			rules.names.Add(pattern)
+		}
+	}
+}
+
+func (rules attrRules) isEmpty() bool {
+	return rules.names.IsEmpty() &&
+		len(rules.globs) == 0 &&
+		len(rules.regexes) == 0 &&
+		!rules.allUser &&
+		!rules.allOpAttr
+}
+
+func (rules attrRules) matches(name string, isOperational bool) bool {
+	lowerName := strings.ToLower(name)
+
+	if rules.names.Contains(lowerName) {
+		return true
+	}
+
+	if isOperational && rules.allOpAttr || !isOperational && rules.allUser {
+		return true
+	}
+
+	for _, glob := range rules.globs {
+		if matched, _ := path.Match(glob, lowerName); matched {
+			return true
+		}
+	}
+
+	for _, regex := range rules.regexes {
+		if regex.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RuleAttrFilter is an AttributeFilter built from inclusion and exclusion
+// rules. Rules may be exact attribute names, glob patterns such as `msDS-*`,
+// regular expressions, or the LDAP selectors `*` for all user attributes and
+// `+` for all operational attributes. Exclusions take precedence over
+// inclusions, and a filter without inclusions includes every attribute
+// that is not excluded.
+type RuleAttrFilter struct {
+	include     attrRules
+	exclude     attrRules
+	operational hashset.StrHashset
+}
+
+// NewRuleAttrFilter constructs a RuleAttrFilter that includes the
+// attributes matched by `includes`.
+func NewRuleAttrFilter(includes ...string) *RuleAttrFilter {
+	filter := &RuleAttrFilter{
+		include: newAttrRules(),
+		exclude: newAttrRules(),
+	}
+
+	filter.SetOperationalAttributes(operationalAttributes...)
+	filter.Include(includes...)
+	return filter
+}
+
+// NewExclusionFilter constructs an AttributeFilter that includes
+// every attribute except those matched by `excludes`.
+func NewExclusionFilter(excludes ...string) AttributeFilter {
+	filter := NewRuleAttrFilter()
+	filter.Exclude(excludes...)
+	return filter
+}
+
+// SetOperationalAttributes replaces the names of the attributes
+// that are selected by `+` and left out by `*`.
+func (f *RuleAttrFilter) SetOperationalAttributes(names ...string) {
+	f.operational = hashset.NewStrHashset()
+	for _, name := range names {
+		f.operational.Add(strings.ToLower(name))
+	}
+}
+
+// Include adds inclusion rules. It is equivalent to Add.
+func (f *RuleAttrFilter) Include(patterns ...string) {
+	f.include.add(patterns...)
+}
+
+// Exclude adds exclusion rules.
+func (f *RuleAttrFilter) Exclude(patterns ...string) {
+	f.exclude.add(patterns...)
+}
+
+// IncludeRegex includes attributes whose name matches `regex`.
+func (f *RuleAttrFilter) IncludeRegex(regex *regexp.Regexp) {
+	f.include.regexes = append(f.include.regexes, regex)
+}
+
+// ExcludeRegex excludes attributes whose name matches `regex`.
+func (f *RuleAttrFilter) ExcludeRegex(regex *regexp.Regexp) {
+	f.exclude.regexes = append(f.exclude.regexes, regex)
+}
+
+func (f *RuleAttrFilter) Add(patterns ...string) {
+	f.Include(patterns...)
+}
+
+// Contains returns true if an inclusion rule matches the attribute name
+func (f RuleAttrFilter) Contains(name string) bool {
+	return f.include.matches(name, f.isOperational(name))
+}
+
+// IsEmpty returns true if the filter has no rules
+func (f RuleAttrFilter) IsEmpty() bool {
+	return f.include.isEmpty() && f.exclude.isEmpty()
+}
+
+func (f RuleAttrFilter) isOperational(name string) bool {
+	return f.operational.Contains(strings.ToLower(name))
+}
+
+// IsFiltered will return true if the filter specifies that
+// the attribute should be excluded
+func (f RuleAttrFilter) IsFiltered(attr entity.Attribute) bool {
+	isOperational := f.isOperational(attr.Name)
+
+	if f.exclude.matches(attr.Name, isOperational) {
+		return true
+	}
+
+	if f.include.isEmpty() {
+		return false
+	}
+
+	return !f.include.matches(attr.Name, isOperational)
+}
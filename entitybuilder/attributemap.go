@@ -1,51 +1,80 @@
 package entitybuilder
 
 import (
-	"github.com/kgoins/ldapentity/entity"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/kgoins/ldifparser/syntax"
 )
 
-type attributeMap map[string]entity.Attribute
+// attrLine is an attribute line split into its name and value.
+// Both are substrings of the source line, so splitting a line
+// does not copy it, unless the value had to be decoded.
+type attrLine struct {
+	name  string
+	value string
+}
 
-func (m attributeMap) GetDN() (entity.Attribute, bool) {
-	var dn entity.Attribute
+// splitAttrLine splits `line` into its name and value. Base64 values,
+// written in `name:: value` format, are decoded so that they hold the
+// same value that was encoded.
+func splitAttrLine(line string) (attrLine, error) {
+	sepIdx := strings.Index(line, ": ")
+	if sepIdx < 1 || sepIdx+2 == len(line) {
+		return attrLine{}, errors.New("malformed attribute line")
+	}
 
-	dn, found := m["dn"]
-	if !found {
-		dn, found = m["distinguishedName"]
+	attr := attrLine{
+		name:  line[:sepIdx],
+		value: line[sepIdx+2:],
 	}
 
-	return dn, found
-}
+	if !strings.HasSuffix(attr.name, ":") {
+		return attr, nil
+	}
+	attr.name = strings.TrimSuffix(attr.name, ":")
 
-func mergeAttributes(a1, a2 entity.Attribute) entity.Attribute {
-	for _, val := range a2.GetValues() {
-		a1.Value.Add(val)
+	decoded, err := base64.StdEncoding.DecodeString(attr.value)
+	if err != nil {
+		return attrLine{}, fmt.Errorf("malformed base64 value of attribute %s: %w", attr.name, err)
 	}
+	attr.value = string(decoded)
 
-	return a1
+	return attr, nil
 }
 
-func newAttributeMap(attrLines []string) (attributeMap, error) {
-	attrs := make(map[string]entity.Attribute)
+// splitAttrLines splits every attribute line of an entity, skipping comments.
+func splitAttrLines(entityLines []string) ([]attrLine, error) {
+	attrLines := make([]attrLine, 0, len(entityLines))
 
-	for _, line := range attrLines {
+	for _, line := range entityLines {
 		if syntax.IsLdifComment(line) {
 			continue
 		}
 
-		attr, err := BuildAttributeFromLine(line)
+		attr, err := splitAttrLine(line)
 		if err != nil {
 			return nil, err
 		}
 
-		if _, attrExists := attrs[attr.Name]; attrExists {
-			existing := attrs[attr.Name]
-			attr = mergeAttributes(existing, attr)
-		}
+		attrLines = append(attrLines, attr)
+	}
 
-		attrs[attr.Name] = attr
+	return attrLines, nil
+}
+
+// getDN returns the first dn value, falling back
+// to the first distinguishedName value.
+func getDN(attrLines []attrLine) (string, bool) {
+	for _, name := range []string{"dn", "distinguishedName"} {
+		for _, attr := range attrLines {
+			if attr.name == name {
+				return attr.value, true
+			}
+		}
 	}
 
-	return attrs, nil
+	return "", false
 }
@@ -2,35 +2,19 @@ package entitybuilder
 
 import (
 	"errors"
-	"strings"
 
 	"github.com/kgoins/ldapentity/entity"
 )
 
-func splitAttrLine(attrLine string) ([]string, error) {
-	lineParts := strings.Split(attrLine, ": ")
-
-	if len(lineParts) != 2 {
-		return nil, errors.New("malformed attribute line")
-	}
-
-	if lineParts[0] == "" || lineParts[1] == "" {
-		return nil, errors.New("malformed attribute line")
-	}
-
-	lineParts[0] = strings.TrimRight(lineParts[0], ":")
-	return lineParts, nil
-}
-
 // BuildAttributeFromLine constructs an LDAP attribute from
 // an LDIF line, which is expected to be in `attrName: value` format.
 func BuildAttributeFromLine(attrLine string) (a entity.Attribute, err error) {
-	attrParts, err := splitAttrLine(attrLine)
+	attr, err := splitAttrLine(attrLine)
 	if err != nil {
 		return
 	}
 
-	a = entity.NewEntityAttribute(attrParts[0], attrParts[1])
+	a = entity.NewEntityAttribute(attr.name, attr.value)
 	return
 }
 
@@ -47,21 +31,37 @@ func BuildEntity(entityLines []string, includeAttrs ...AttributeFilter) (e entit
 		attrFilter = includeAttrs[0]
 	}
 
-	attrMap, err := newAttributeMap(entityLines)
+	attrLines, err := splitAttrLines(entityLines)
 	if err != nil {
 		return
 	}
 
-	dn, found := attrMap.GetDN()
-	if !found || len(dn.GetValues()) < 1 {
+	dn, found := getDN(attrLines)
+	if !found {
 		err = errors.New("unable to find entity DN")
 		return
 	}
 
-	e = entity.NewEntity(dn.GetValues()[0])
-	for _, attr := range attrMap {
-		if !attrFilter.IsFiltered(attr) {
-			e.AddAttribute(attr)
+	e = entity.NewEntity(dn)
+
+	// consecutive values of an attribute are added together so that
+	// the filter is checked and the attribute is built once per run
+	vals := make([]string, 0, len(attrLines))
+	for i := 0; i < len(attrLines); {
+		name := attrLines[i].name
+
+		vals = vals[:0]
+		for ; i < len(attrLines) && attrLines[i].name == name; i++ {
+			vals = append(vals, attrLines[i].value)
+		}
+
+		// NewEntity has already added the DN
+		if name == "dn" && len(vals) == 1 {
+			continue
+		}
+
+		if !attrFilter.IsFiltered(entity.Attribute{Name: name}) {
+			e.AddAttribute(entity.NewEntityAttribute(name, vals...))
 		}
 	}
 
@@ -1,8 +1,10 @@
 package entitybuilder_test
 
 import (
+	"regexp"
 	"testing"
 
+	"github.com/kgoins/ldapentity/entity"
 	"github.com/kgoins/ldapentity/entity/ad"
 	"github.com/kgoins/ldifparser/entitybuilder"
 	"github.com/stretchr/testify/require"
@@ -98,3 +100,313 @@ func TestEntityBuilder_BuildFromAttrList_MalformedTitle(t *testing.T) {
 	_, err := entitybuilder.BuildEntity(attrLines)
 	r.Error(err)
 }
+
+func TestEntityBuilder_BuildRecord(t *testing.T) {
+	r := require.New(t)
+
+	attrLines := []string{
+		"# MYPC, ContosoUsers, contoso.com",
+		"dn: CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com",
+		"objectClass: top",
+		"objectClass: computer",
+		"objectClass: top",
+		"description: first: second",
+		"objectGUID:: 7OBfD10nQkSVYY8UHCV2aQ==",
+		"jpegPhoto:< file:///tmp/photo.jpg",
+		"sAMAccountName: MYPC$",
+		"description:",
+	}
+
+	rec, err := entitybuilder.BuildRecord(attrLines)
+	r.NoError(err)
+
+	r.Equal([]string{"# MYPC, ContosoUsers, contoso.com"}, rec.Comments)
+	r.Len(rec.Attributes, 7)
+
+	names := []string{}
+	for _, attr := range rec.Attributes {
+		names = append(names, attr.Name)
+	}
+	r.Equal([]string{
+		"dn", "objectClass", "description", "objectGUID",
+		"jpegPhoto", "sAMAccountName", "description",
+	}, names)
+
+	r.Equal([]string{"top", "computer", "top"}, rec.Attributes[1].GetValues())
+	r.Equal(entitybuilder.Base64Encoding, rec.Attributes[3].Values[0].Encoding)
+	r.Equal(entitybuilder.URLEncoding, rec.Attributes[4].Values[0].Encoding)
+
+	guid, err := rec.Attributes[3].Values[0].Decode()
+	r.NoError(err)
+	r.Len(guid, 16)
+
+	desc, found := rec.GetAttribute("DESCRIPTION")
+	r.True(found)
+	r.Equal([]string{"first: second", ""}, desc.GetValues())
+
+	e, err := rec.ToEntity()
+	r.NoError(err)
+	r.Equal(6, e.Size())
+
+	objClass, found := e.GetAttribute("objectclass")
+	r.True(found)
+	r.Len(objClass.GetValues(), 2)
+}
+
+func TestEntityBuilder_BuildRecord_AttrFilter(t *testing.T) {
+	r := require.New(t)
+
+	attrFilter := entitybuilder.NewAttributeFilter(ad.ATTR_sAMAccountName)
+
+	rec, err := entitybuilder.BuildRecord(defaultTestAttrLines, attrFilter)
+	r.NoError(err)
+	r.Len(rec.Attributes, 2)
+
+	dn, found := rec.GetDN()
+	r.True(found)
+	r.Equal("CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com", dn)
+
+	parsedDN, err := rec.GetParsedDN()
+	r.NoError(err)
+	r.Equal("ContosoUsers", parsedDN.Parent().RDN().AVAs[0].Value)
+}
+
+func TestEntityBuilder_BuildRecord_MissingDN(t *testing.T) {
+	r := require.New(t)
+
+	_, err := entitybuilder.BuildRecord([]string{"cn: MYPC"})
+	r.Error(err)
+}
+
+func TestEntityBuilder_RuleAttrFilter(t *testing.T) {
+	r := require.New(t)
+
+	testCases := []struct {
+		filter   entitybuilder.AttributeFilter
+		included []string
+		filtered []string
+	}{
+		{
+			filter:   entitybuilder.NewExclusionFilter("nTSecurityDescriptor", "thumbnailPhoto"),
+			included: []string{"cn", "sAMAccountName", "createTimestamp"},
+			filtered: []string{"ntsecuritydescriptor", "thumbnailPhoto"},
+		},
+		{
+			filter:   entitybuilder.NewRuleAttrFilter("msDS-*", "ms-Mcs-*", "cn"),
+			included: []string{"msDS-SupportedEncryptionTypes", "ms-Mcs-AdmPwd", "CN"},
+			filtered: []string{"sAMAccountName", "ms-DS-MachineAccountQuota"},
+		},
+		{
+			filter:   entitybuilder.NewRuleAttrFilter("*"),
+			included: []string{"cn", "memberOf"},
+			filtered: []string{"createTimestamp", "entryUUID"},
+		},
+		{
+			filter:   entitybuilder.NewRuleAttrFilter("+"),
+			included: []string{"createTimestamp", "entryUUID"},
+			filtered: []string{"cn", "memberOf"},
+		},
+		{
+			filter:   entitybuilder.NewRuleAttrFilter("*", "+"),
+			included: []string{"cn", "createTimestamp"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		for _, name := range testCase.included {
+			r.False(testCase.filter.IsFiltered(entity.NewEntityAttribute(name)), name)
+		}
+
+		for _, name := range testCase.filtered {
+			r.True(testCase.filter.IsFiltered(entity.NewEntityAttribute(name)), name)
+		}
+	}
+}
+
+func TestEntityBuilder_RuleAttrFilter_ExcludeOverridesInclude(t *testing.T) {
+	r := require.New(t)
+
+	filter := entitybuilder.NewRuleAttrFilter("*")
+	filter.Exclude("user*")
+	filter.ExcludeRegex(regexp.MustCompile(`(?i)^objectsid$`))
+	filter.IncludeRegex(regexp.MustCompile(`^pwd`))
+
+	r.False(filter.IsEmpty())
+	r.True(filter.Contains("userCertificate"))
+
+	r.False(filter.IsFiltered(entity.NewEntityAttribute("cn")))
+	r.False(filter.IsFiltered(entity.NewEntityAttribute("pwdChangedTime")))
+	r.True(filter.IsFiltered(entity.NewEntityAttribute("userCertificate")))
+	r.True(filter.IsFiltered(entity.NewEntityAttribute("objectSid")))
+
+	filter.SetOperationalAttributes("cn")
+	r.True(filter.IsFiltered(entity.NewEntityAttribute("cn")))
+}
+
+func TestEntityBuilder_BuildFromAttrList_ExclusionFilter(t *testing.T) {
+	r := require.New(t)
+
+	attrFilter := entitybuilder.NewExclusionFilter("objectClass", "sAMAccount*")
+
+	e, err := entitybuilder.BuildEntity(defaultTestAttrLines, attrFilter)
+	r.NoError(err)
+	r.Equal(2, e.Size()) // only CN and DN should remain
+
+	_, found := e.GetAttribute("cn")
+	r.True(found)
+}
+
+func TestEntityBuilder_BuildAttributeFromLine_ValueWithSeparator(t *testing.T) {
+	r := require.New(t)
+
+	attr, err := entitybuilder.BuildAttributeFromLine("description: first: second")
+	r.NoError(err)
+
+	r.Equal("description", attr.Name)
+	r.Equal([]string{"first: second"}, attr.GetValues())
+}
+
+func TestEntityBuilder_BuildFromAttrList_MergesValues(t *testing.T) {
+	r := require.New(t)
+
+	attrLines := []string{
+		"dn: CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com",
+		"objectClass: top",
+		"objectClass: computer",
+		"cn: MYPC",
+		"objectClass: top",
+		"objectClass: device",
+	}
+
+	e, err := entitybuilder.BuildEntity(attrLines)
+	r.NoError(err)
+	r.Equal(3, e.Size())
+
+	objectClass, found := e.GetAttribute("objectClass")
+	r.True(found)
+	r.ElementsMatch([]string{"top", "computer", "device"}, objectClass.GetValues())
+}
+
+func TestEntityBuilder_BuildAttributeFromLine_Base64Value(t *testing.T) {
+	r := require.New(t)
+
+	attr, err := entitybuilder.BuildAttributeFromLine("description:: IGxlYWQ=")
+	r.NoError(err)
+
+	r.Equal("description", attr.Name)
+	r.Equal([]string{" lead"}, attr.GetValues())
+
+	_, err = entitybuilder.BuildAttributeFromLine("description:: not base64!")
+	r.Error(err)
+}
+
+func TestEntityBuilder_BuildRecord_InnerComments(t *testing.T) {
+	r := require.New(t)
+
+	attrLines := []string{
+		"# MYPC, ContosoUsers, contoso.com",
+		"dn: CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com",
+		"objectClass: top",
+		"# split objectClass",
+		"objectClass: computer",
+		"# skipped with cn",
+		"cn: MYPC",
+		"sAMAccountName: MYPC$",
+		"# trailing",
+	}
+
+	attrFilter := entitybuilder.NewAttributeFilter("objectClass", ad.ATTR_sAMAccountName)
+	rec, err := entitybuilder.BuildRecord(attrLines, attrFilter)
+	r.NoError(err)
+
+	r.Equal([]string{"# MYPC, ContosoUsers, contoso.com"}, rec.Comments)
+	r.Len(rec.Attributes, 4)
+
+	r.Empty(rec.Attributes[1].Comments)
+	r.Equal([]string{"# split objectClass"}, rec.Attributes[2].Comments)
+	r.Equal([]string{"computer"}, rec.Attributes[2].GetValues())
+	r.Equal([]string{"# skipped with cn"}, rec.Attributes[3].Comments)
+	r.Equal("sAMAccountName", rec.Attributes[3].Name)
+	r.Equal([]string{"# trailing"}, rec.TrailingComments)
+
+	objectClass, found := rec.GetAttribute("objectClass")
+	r.True(found)
+	r.Equal([]string{"top", "computer"}, objectClass.GetValues())
+}
+
+func TestEntityBuilder_RecordToEntityMatchesBuildEntity(t *testing.T) {
+	r := require.New(t)
+
+	attrLines := []string{
+		"# MYPC, ContosoUsers, contoso.com",
+		"dn:: Q049TVlQQyxPVT1Db250b3NvVXNlcnMsREM9Y29udG9zbyxEQz1jb20=",
+		"objectClass: top",
+		"objectClass: computer",
+		"description:: IGxlYWQ=",
+		"description: second",
+		"objectGUID:: 7OBfD10nQkSVYY8UHCV2aQ==",
+		"sAMAccountName: MYPC$",
+	}
+
+	built, err := entitybuilder.BuildEntity(attrLines)
+	r.NoError(err)
+
+	rec, err := entitybuilder.BuildRecord(attrLines)
+	r.NoError(err)
+	converted, err := rec.ToEntity()
+	r.NoError(err)
+
+	r.True(built.Equals(converted))
+
+	dn, _ := converted.GetDN()
+	r.Equal("CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com", dn)
+
+	description, found := converted.GetAttribute("description")
+	r.True(found)
+	r.ElementsMatch([]string{" lead", "second"}, description.GetValues())
+}
+
+func TestEntityBuilder_BuildRecordFromText(t *testing.T) {
+	r := require.New(t)
+
+	text := "# MYPC, ContosoUsers, contoso.com\r\n" +
+		"dn: CN=MYPC,OU=ContosoUsers,\r\n" +
+		" DC=contoso,DC=com\r\n" +
+		"cn:  MYPC\r\n" +
+		"objectGUID:: 7OBfD10nQkSV\r\n" +
+		" YY8UHCV2aQ==\r\n"
+
+	rec, err := entitybuilder.BuildRecordFromText(text)
+	r.NoError(err)
+
+	r.Equal([]string{"# MYPC, ContosoUsers, contoso.com"}, rec.Comments)
+	r.Len(rec.Attributes, 3)
+
+	dn, _ := rec.GetDN()
+	r.Equal("CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com", dn)
+
+	source, isUnchanged := rec.Attributes[0].Values[0].SourceLines("dn")
+	r.True(isUnchanged)
+	r.Equal("dn: CN=MYPC,OU=ContosoUsers,\n DC=contoso,DC=com", source)
+
+	cn := rec.Attributes[1].Values[0]
+	r.Equal("MYPC", cn.Value)
+	source, isUnchanged = cn.SourceLines("cn")
+	r.True(isUnchanged)
+	r.Equal("cn:  MYPC", source)
+
+	guid := rec.Attributes[2].Values[0]
+	r.Equal(entitybuilder.Base64Encoding, guid.Encoding)
+	r.Equal("7OBfD10nQkSVYY8UHCV2aQ==", guid.Value)
+
+	// sources no longer apply once a value or name changes
+	_, isUnchanged = cn.SourceLines("CN")
+	r.False(isUnchanged)
+
+	cn.Value = "OTHERPC"
+	_, isUnchanged = cn.SourceLines("cn")
+	r.False(isUnchanged)
+
+	_, isUnchanged = entitybuilder.RecordValue{Value: "MYPC"}.SourceLines("cn")
+	r.False(isUnchanged)
+}
@@ -0,0 +1,327 @@
+package entitybuilder
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser/syntax"
+)
+
+// ValueEncoding identifies how a value is written in LDIF.
+type ValueEncoding int
+
+const (
+	// PlainEncoding values are written as `name: value`
+	PlainEncoding ValueEncoding = iota
+	// Base64Encoding values are written as `name:: value`
+	Base64Encoding
+	// URLEncoding values are written as `name:< value`
+	URLEncoding
+)
+
+// Separator returns the characters placed between an
+// attribute name and a value with this encoding.
+func (enc ValueEncoding) Separator() string {
+	switch enc {
+	case Base64Encoding:
+		return ":: "
+	case URLEncoding:
+		return ":< "
+	default:
+		return ": "
+	}
+}
+
+// RecordValue is a single attribute value as it appears in the source.
+// Base64 and URL values hold the encoded text, not the data it refers to.
+type RecordValue struct {
+	Value    string
+	Encoding ValueEncoding
+
+	// Source is the attribute line the value was read from, with its
+	// folds and the spacing after its separator, when the record was
+	// built by BuildRecordFromText. Folded lines are joined by `\n`.
+	Source string
+}
+
+// SourceLines returns the Source of the value, or false if there is none
+// or it no longer holds `name` and the value, as the value has changed.
+func (v RecordValue) SourceLines(name string) (string, bool) {
+	if v.Source == "" {
+		return "", false
+	}
+
+	srcName, srcVal, err := ParseAttributeLine(strings.ReplaceAll(v.Source, "\n ", ""))
+	if err != nil || srcName != name || srcVal.Value != v.Value || srcVal.Encoding != v.Encoding {
+		return "", false
+	}
+
+	return v.Source, true
+}
+
+// Decode returns the raw bytes of the value. URL values
+// are not resolved and will return an error.
+func (v RecordValue) Decode() ([]byte, error) {
+	switch v.Encoding {
+	case Base64Encoding:
+		return base64.StdEncoding.DecodeString(v.Value)
+	case URLEncoding:
+		return nil, errors.New("unable to decode URL value")
+	default:
+		return []byte(v.Value), nil
+	}
+}
+
+// RecordAttribute is a run of consecutive values for a single attribute.
+// The attribute name keeps the casing used in the source.
+type RecordAttribute struct {
+	// Comments are the comment lines between this
+	// attribute and the attribute before it.
+	Comments []string
+	Name     string
+	Values   []RecordValue
+}
+
+// GetValues returns the text of each value in source order.
+func (a RecordAttribute) GetValues() []string {
+	vals := make([]string, 0, len(a.Values))
+	for _, val := range a.Values {
+		vals = append(vals, val.Value)
+	}
+
+	return vals
+}
+
+// Record is an LDIF record that remembers the attribute order, value order,
+// attribute name casing, value encodings and comments of its source. Unlike
+// an Entity, duplicate values are kept. Attributes whose values are not on
+// consecutive lines in the source, or are split by a comment, are kept as
+// separate RecordAttributes. Records built by BuildRecordFromText also
+// remember the source lines of each value, including where they were
+// folded and how many spaces followed the separator.
+type Record struct {
+	// Comments are the comment lines, such as the title,
+	// that precede the first attribute of the record.
+	Comments   []string
+	Attributes []RecordAttribute
+	// TrailingComments are the comment lines
+	// that follow the last attribute.
+	TrailingComments []string
+}
+
+// GetAttribute returns all values of the named attribute in source order.
+// Attribute names are compared case-insensitively.
+func (rec Record) GetAttribute(name string) (RecordAttribute, bool) {
+	merged := RecordAttribute{}
+	found := false
+
+	for _, attr := range rec.Attributes {
+		if !strings.EqualFold(attr.Name, name) {
+			continue
+		}
+
+		if !found {
+			merged.Name = attr.Name
+			found = true
+		}
+		merged.Values = append(merged.Values, attr.Values...)
+	}
+
+	return merged, found
+}
+
+// GetDN returns the value of the record's dn or
+// distinguishedName attribute if either is present.
+func (rec Record) GetDN() (string, bool) {
+	for _, name := range []string{"dn", "distinguishedName"} {
+		attr, found := rec.GetAttribute(name)
+		if found && len(attr.Values) > 0 {
+			return attr.Values[0].Value, true
+		}
+	}
+
+	return "", false
+}
+
+// GetParsedDN returns the record's DN parsed into its RDN components.
+func (rec Record) GetParsedDN() (syntax.DN, error) {
+	dn, found := rec.GetDN()
+	if !found {
+		return syntax.DN{}, errors.New("unable to find entity DN")
+	}
+
+	return syntax.ParseDN(dn)
+}
+
+// ToEntity converts the record into an Entity. Values are stored
+// the same way BuildEntity stores them, so base64 values are decoded.
+// URL values keep their URL, as they are never resolved.
+func (rec Record) ToEntity() (e entity.Entity, err error) {
+	dnAttr, found := rec.GetAttribute("dn")
+	if !found {
+		dnAttr, found = rec.GetAttribute("distinguishedName")
+	}
+	if !found || len(dnAttr.Values) == 0 {
+		err = errors.New("unable to find entity DN")
+		return
+	}
+
+	dn, err := dnAttr.Values[0].decodeText()
+	if err != nil {
+		return
+	}
+
+	e = entity.NewEntity(dn)
+	for _, attr := range rec.Attributes {
+		vals := make([]string, 0, len(attr.Values))
+		for _, val := range attr.Values {
+			decoded, decodeErr := val.decodeText()
+			if decodeErr != nil {
+				return entity.Entity{}, decodeErr
+			}
+			vals = append(vals, decoded)
+		}
+
+		e.AddAttribute(entity.NewEntityAttribute(attr.Name, vals...))
+	}
+
+	return
+}
+
+// decodeText returns the value as it is stored in an Entity.
+func (v RecordValue) decodeText() (string, error) {
+	if v.Encoding == URLEncoding {
+		return v.Value, nil
+	}
+
+	decoded, err := v.Decode()
+	if err != nil {
+		return "", fmt.Errorf("malformed base64 value %q: %w", v.Value, err)
+	}
+
+	return string(decoded), nil
+}
+
+// ParseAttributeLine splits an unfolded LDIF attribute line into its
+// attribute name and value, keeping the encoding of the value. The
+// spaces between the separator and the value are not kept.
+func ParseAttributeLine(attrLine string) (name string, val RecordValue, err error) {
+	sepIdx := strings.IndexByte(attrLine, ':')
+	if sepIdx < 1 {
+		err = errors.New("malformed attribute line")
+		return
+	}
+
+	name = attrLine[:sepIdx]
+	valStr := attrLine[sepIdx+1:]
+
+	switch {
+	case strings.HasPrefix(valStr, ":"):
+		val.Encoding = Base64Encoding
+		valStr = valStr[1:]
+	case strings.HasPrefix(valStr, "<"):
+		val.Encoding = URLEncoding
+		valStr = valStr[1:]
+	}
+
+	val.Value = strings.TrimLeft(valStr, " ")
+	return
+}
+
+// BuildRecord constructs a Record from a list of LDIF lines, keeping only
+// the attributes allowed by the optional AttributeFilter. The DN is always kept.
+// `entityLines` are expected to be unfolded, as returned by the LdifReader.
+func BuildRecord(entityLines []string, includeAttrs ...AttributeFilter) (rec Record, err error) {
+	return buildRecord(entityLines, nil, includeAttrs...)
+}
+
+// BuildRecordFromText constructs a Record from the text of a single LDIF
+// record, as it appears in the input, the same way as BuildRecord. Each
+// value keeps its source lines, so that unchanged values can be written
+// back exactly as they were read. Comments are unfolded.
+func BuildRecordFromText(text string, includeAttrs ...AttributeFilter) (rec Record, err error) {
+	var lines []string
+	var sources []string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		last := len(lines) - 1
+		if syntax.IsContinuationLine(line) && last >= 0 {
+			lines[last] += line[1:]
+			sources[last] += "\n" + line
+			continue
+		}
+
+		lines = append(lines, line)
+		sources = append(sources, line)
+	}
+
+	return buildRecord(lines, sources, includeAttrs...)
+}
+
+// buildRecord constructs a Record from unfolded lines. `sources` holds
+// the source lines of each line, or is nil if they are not known.
+func buildRecord(entityLines []string, sources []string, includeAttrs ...AttributeFilter) (rec Record, err error) {
+	var attrFilter AttributeFilter
+	if len(includeAttrs) == 0 || includeAttrs[0] == nil {
+		attrFilter = NewAttributeFilter()
+	} else {
+		attrFilter = includeAttrs[0]
+	}
+
+	// comments after the first attribute are
+	// kept with the attribute that follows them
+	var comments []string
+
+	for i, line := range entityLines {
+		if syntax.IsLdifComment(line) {
+			if len(rec.Attributes) == 0 {
+				rec.Comments = append(rec.Comments, line)
+			} else {
+				comments = append(comments, line)
+			}
+			continue
+		}
+
+		name, val, parseErr := ParseAttributeLine(line)
+		if parseErr != nil {
+			err = parseErr
+			return
+		}
+		if sources != nil {
+			val.Source = sources[i]
+		}
+
+		isDN := strings.EqualFold(name, "dn")
+		if !isDN && attrFilter.IsFiltered(entity.Attribute{Name: name}) {
+			continue
+		}
+
+		numAttrs := len(rec.Attributes)
+		if numAttrs > 0 && len(comments) == 0 && rec.Attributes[numAttrs-1].Name == name {
+			rec.Attributes[numAttrs-1].Values = append(rec.Attributes[numAttrs-1].Values, val)
+			continue
+		}
+
+		rec.Attributes = append(rec.Attributes, RecordAttribute{
+			Comments: comments,
+			Name:     name,
+			Values:   []RecordValue{val},
+		})
+		comments = nil
+	}
+	rec.TrailingComments = comments
+
+	if _, found := rec.GetDN(); !found {
+		err = errors.New("unable to find entity DN")
+	}
+
+	return
+}
@@ -0,0 +1,84 @@
+package ldifparser
+
+import (
+	"os"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// ReadFilesChanneled reads the entities of each file in `paths`, one file
+// after another, and returns them via a single channel. The SourceName of
+// `conf` is replaced with each file's path, so that the metadata of every
+// entity names the file it came from. Files that cannot be opened are
+// reported as errors, and errors are otherwise handled the same way as in
+// ReadEntitiesChanneled.
+//
+// Resume tokens don't record which file they were taken from, so ResumeFrom
+// only applies to the first file. To resume a read, pass the paths starting
+// with the Source of the entity that the token was taken from.
+func ReadFilesChanneled(paths []string, interrupt <-chan bool, conf ...ReaderConf) <-chan EntityResp {
+	readerConf := NewReaderConf()
+	if len(conf) > 0 {
+		readerConf = conf[0]
+	}
+
+	results := make(chan EntityResp)
+
+	go func() {
+		defer close(results)
+
+		for i, path := range paths {
+			fileConf := readerConf
+			fileConf.SourceName = path
+			if i > 0 {
+				fileConf.ResumeFrom = ResumeToken{}
+			}
+
+			if !readFileInto(path, fileConf, results, interrupt) {
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+// readFileInto sends the entities of the file at `path` to `results`. It
+// returns false once reading should stop, because `interrupt` was closed
+// or an error occurred and ContinueOnErr is not set.
+func readFileInto(path string, conf ReaderConf, results chan<- EntityResp, interrupt <-chan bool) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		resp := EntityResp{
+			Error: merry.Prependf(err, "unable to open %s", path),
+			Meta:  EntityMeta{Source: path},
+		}
+
+		select {
+		case results <- resp:
+		case <-interrupt:
+			return false
+		}
+
+		return conf.ContinueOnErr
+	}
+	defer file.Close()
+
+	// the reader stops on its own once interrupted, so the
+	// file stays open until the reader's channel is drained
+	shouldContinue := true
+	for resp := range NewLdifReader(file, conf).ReadEntitiesChanneled(interrupt) {
+		if !shouldContinue {
+			continue
+		}
+
+		select {
+		case results <- resp:
+			shouldContinue = resp.Error == nil || conf.ContinueOnErr
+		case <-interrupt:
+			shouldContinue = false
+		}
+	}
+
+	return shouldContinue
+}
@@ -0,0 +1,93 @@
+package ldifparser_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles_ReadFilesChanneled(t *testing.T) {
+	r := require.New(t)
+
+	secondFile := filepath.Join(t.TempDir(), "second.ldif")
+	err := os.WriteFile(secondFile, []byte("dn: CN=USR2,DC=contoso,DC=com\ncn: USR2\n"), 0600)
+	r.NoError(err)
+
+	missingFile := filepath.Join(t.TempDir(), "missing.ldif")
+	paths := []string{
+		filepath.Join(getTestDataDir(), testFileName),
+		missingFile,
+		secondFile,
+	}
+
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	results := []ldifparser.EntityResp{}
+	for resp := range ldifparser.ReadFilesChanneled(paths, interrupt) {
+		results = append(results, resp)
+	}
+	r.Len(results, numTestFileEntities+2)
+
+	for _, resp := range results[:numTestFileEntities] {
+		r.NoError(resp.Error)
+		r.Equal(paths[0], resp.Meta.Source)
+	}
+
+	r.Error(results[numTestFileEntities].Error)
+	r.Equal(missingFile, results[numTestFileEntities].Meta.Source)
+
+	last := results[numTestFileEntities+1]
+	r.NoError(last.Error)
+	r.Equal(secondFile, last.Meta.Source)
+	r.Equal(1, last.Meta.Line)
+
+	// reading stops at the missing file
+	conf := ldifparser.NewReaderConf()
+	conf.ContinueOnErr = false
+
+	results = []ldifparser.EntityResp{}
+	for resp := range ldifparser.ReadFilesChanneled(paths, interrupt, conf) {
+		results = append(results, resp)
+	}
+	r.Len(results, numTestFileEntities+1)
+}
+
+func TestFiles_ReadFilesChanneled_ResumeFrom(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	firstFile := filepath.Join(dir, "a.ldif")
+	secondFile := filepath.Join(dir, "b.ldif")
+
+	twoEntities := "dn: CN=%[1]s1,DC=contoso,DC=com\ncn: %[1]s1\n\ndn: CN=%[1]s2,DC=contoso,DC=com\ncn: %[1]s2\n"
+	r.NoError(os.WriteFile(firstFile, []byte(fmt.Sprintf(twoEntities, "A")), 0600))
+	r.NoError(os.WriteFile(secondFile, []byte(fmt.Sprintf(twoEntities, "B")), 0600))
+	paths := []string{firstFile, secondFile}
+
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	first := <-ldifparser.ReadFilesChanneled(paths, interrupt)
+	r.NoError(first.Error)
+
+	conf := ldifparser.NewReaderConf()
+	conf.ResumeFrom = first.Resume
+
+	dns := []string{}
+	for resp := range ldifparser.ReadFilesChanneled(paths, interrupt, conf) {
+		r.NoError(resp.Error)
+		dn, _ := resp.Entity.GetDN()
+		dns = append(dns, dn)
+	}
+
+	r.Equal([]string{
+		"CN=A2,DC=contoso,DC=com",
+		"CN=B1,DC=contoso,DC=com",
+		"CN=B2,DC=contoso,DC=com",
+	}, dns)
+}
@@ -0,0 +1,99 @@
+package ldifparser
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FollowEntities streams the entities of an input that is still being
+// written, such as a file that another process appends to. It works like
+// ReadEntitiesChanneled, except that at the end of the input it waits for
+// more data instead of closing the channel. A record is only read once the
+// blank line that ends it has been written, so a partially written record at
+// the end of the input is never reported as an error. Reading starts at
+// ResumeFrom, if set, and the channel is closed once `ctx` is canceled or a
+// read error occurs.
+//
+// Entities are always read sequentially. The input must be one whose ReadAt
+// sees appended data, such as an os.File; in-memory inputs and memory mapped
+// files never grow.
+func (r LdifReader) FollowEntities(ctx context.Context) <-chan EntityResp {
+	results := make(chan EntityResp)
+
+	pollInterval := r.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	go func() {
+		defer close(results)
+
+		for {
+			next, shouldContinue := r.readAppended(ctx, results)
+			if !shouldContinue {
+				return
+			}
+			r.ResumeFrom = next
+
+			timer := time.NewTimer(pollInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return results
+}
+
+// readAppended sends the entities of the terminated records that follow
+// ResumeFrom to `results`. It returns the position after the last record
+// that was sent, and false once following should stop.
+func (r LdifReader) readAppended(ctx context.Context, results chan<- EntityResp) (ResumeToken, bool) {
+	next := r.ResumeFrom
+
+	scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+	if errors.Is(err, errNoEntityBlock) {
+		r.Logger.Debug("waiting for the first entity")
+		return next, true
+	}
+
+	if err != nil {
+		select {
+		case results <- EntityResp{Error: err, Meta: EntityMeta{Source: r.SourceName}}:
+		case <-ctx.Done():
+		}
+		return next, false
+	}
+
+	shouldContinue := true
+	r.scanBlocks(scanner, blockPos, firstLine, newLineFilter(r.AttributeFilter), func(block entityBlock, err error) bool {
+		// the rest of the record may not have been written yet
+		if err == nil && !block.terminated {
+			return false
+		}
+
+		resp := r.buildEntityResp(block, err)
+		select {
+		case results <- resp:
+		case <-ctx.Done():
+			shouldContinue = false
+			return false
+		}
+
+		// the scanner can't recover from read errors,
+		// so reading again would only repeat the error
+		if err != nil || (resp.Error != nil && !r.ContinueOnErr) {
+			shouldContinue = false
+			return false
+		}
+
+		next = resp.Resume
+		return true
+	})
+
+	return next, shouldContinue
+}
@@ -0,0 +1,117 @@
+package ldifparser_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+// followTimeout bounds how long a test waits for a followed entity
+const followTimeout = 5 * time.Second
+
+func receiveFollowed(t *testing.T, results <-chan ldifparser.EntityResp) ldifparser.EntityResp {
+	select {
+	case resp, ok := <-results:
+		require.True(t, ok, "results closed early")
+		return resp
+	case <-time.After(followTimeout):
+		require.FailNow(t, "timed out waiting for an entity")
+	}
+
+	return ldifparser.EntityResp{}
+}
+
+func appendToFile(t *testing.T, file *os.File, text string) {
+	_, err := file.WriteString(text)
+	require.NoError(t, err)
+}
+
+func followTestConf() ldifparser.ReaderConf {
+	conf := ldifparser.NewReaderConf()
+	conf.PollInterval = 5 * time.Millisecond
+	return conf
+}
+
+func TestFollow_FollowEntities(t *testing.T) {
+	r := require.New(t)
+
+	filePath := filepath.Join(t.TempDir(), "follow.ldif")
+	writer, err := os.Create(filePath)
+	r.NoError(err)
+	defer writer.Close()
+
+	reader, err := os.Open(filePath)
+	r.NoError(err)
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := ldifparser.NewLdifReader(reader, followTestConf()).FollowEntities(ctx)
+
+	// nothing has been written yet
+	appendToFile(t, writer, "version: 1\n\n# USR1, contoso.com\n")
+	appendToFile(t, writer, "dn: CN=USR1,DC=contoso,DC=com\ncn: USR1\n\n")
+	appendToFile(t, writer, "dn: CN=USR2,DC=cont")
+
+	first := receiveFollowed(t, results)
+	r.NoError(first.Error)
+	r.Equal("USR1, contoso.com", first.Meta.Title)
+
+	// the partially written record is not reported
+	select {
+	case resp := <-results:
+		r.FailNow("unexpected entity", "%+v", resp)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	appendToFile(t, writer, "oso,DC=com\ncn: USR2\n")
+	appendToFile(t, writer, "\nnot an ldif line\n\n")
+
+	second := receiveFollowed(t, results)
+	r.NoError(second.Error)
+	dn, _ := second.Entity.GetDN()
+	r.Equal("CN=USR2,DC=contoso,DC=com", dn)
+	r.Equal(7, second.Meta.Line)
+
+	// malformed records are still reported
+	r.Error(receiveFollowed(t, results).Error)
+
+	cancel()
+	for range results {
+	}
+}
+
+func TestFollow_ResumeFrom(t *testing.T) {
+	r := require.New(t)
+
+	filePath := filepath.Join(t.TempDir(), "follow.ldif")
+	ldif := "dn: CN=USR1,DC=contoso,DC=com\n\ndn: CN=USR2,DC=contoso,DC=com\n\n"
+	r.NoError(os.WriteFile(filePath, []byte(ldif), 0600))
+
+	reader, err := os.Open(filePath)
+	r.NoError(err)
+	defer reader.Close()
+
+	entities := ldifparser.NewLdifReader(reader).ReadEntities()
+	r.Len(entities, 2)
+
+	conf := followTestConf()
+	conf.ResumeFrom = entities[0].Resume
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := ldifparser.NewLdifReader(reader, conf).FollowEntities(ctx)
+
+	resp := receiveFollowed(t, results)
+	r.NoError(resp.Error)
+	r.Equal(entities[1].Meta, resp.Meta)
+
+	cancel()
+	for range results {
+	}
+}
@@ -4,10 +4,8 @@ go 1.16
 
 require (
 	github.com/ansel1/merry/v2 v2.0.0-beta.10
-	github.com/kgoins/backscanner v1.0.1
 	github.com/kgoins/hashset v0.2.0
 	github.com/kgoins/ldapentity v0.1.0
-	github.com/kgoins/poscanner v1.1.0
 	github.com/stretchr/testify v1.7.0
 	go.uber.org/zap v1.17.0
 )
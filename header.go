@@ -0,0 +1,112 @@
+package ldifparser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Header describes the version line and ldapsearch-style
+// prologue comments written at the start of an LDIF file.
+type Header struct {
+	// Version writes the `version: 1` line when set
+	Version bool
+
+	Base       string
+	Scope      string
+	Filter     string
+	Attributes []string
+
+	Generator string
+	Timestamp time.Time
+}
+
+const ldifVersion int = 1
+
+// hasPrologue returns true if any of the
+// prologue comment fields have been set.
+func (h Header) hasPrologue() bool {
+	return h.Base != "" ||
+		h.Scope != "" ||
+		h.Filter != "" ||
+		len(h.Attributes) > 0 ||
+		h.Generator != "" ||
+		!h.Timestamp.IsZero()
+}
+
+// prologueLines builds the comment block in the same
+// format that ldapsearch uses for its output.
+func (h Header) prologueLines() []string {
+	scope := h.Scope
+	if scope == "" {
+		scope = "subtree"
+	}
+
+	filter := h.Filter
+	if filter == "" {
+		filter = "(objectclass=*)"
+	}
+
+	requesting := "ALL"
+	if len(h.Attributes) > 0 {
+		requesting = strings.Join(h.Attributes, " ")
+	}
+
+	lines := []string{
+		"# extended LDIF",
+		"#",
+		"# LDAPv3",
+		fmt.Sprintf("# base <%s> with scope %s", h.Base, scope),
+		fmt.Sprintf("# filter: %s", filter),
+		fmt.Sprintf("# requesting: %s", requesting),
+	}
+
+	if h.Generator != "" {
+		lines = append(lines, fmt.Sprintf("# generator: %s", h.Generator))
+	}
+
+	if !h.Timestamp.IsZero() {
+		timestamp := h.Timestamp.UTC().Format("20060102150405Z")
+		lines = append(lines, fmt.Sprintf("# timestamp: %s", timestamp))
+	}
+
+	return append(lines, "#")
+}
+
+// WriteHeader writes the version line and prologue comments described
+// by `h`. It should be called before any entities are written.
+func (w *LdifWriter) WriteHeader(h Header) error {
+	if h.Version {
+		w.writeLine(fmt.Sprintf("version: %d", ldifVersion))
+		w.write("\n")
+	}
+
+	if h.hasPrologue() {
+		for _, line := range h.prologueLines() {
+			w.writeLine(line)
+		}
+		w.write("\n")
+	}
+
+	return w.err
+}
+
+// WriteTrailer writes the search result block and entry count
+// comments that ldapsearch appends to its output. It should be
+// called after all entities have been written.
+func (w *LdifWriter) WriteTrailer() error {
+	trailerLines := []string{
+		"# search result",
+		"search: 2",
+		"result: 0 Success",
+		"",
+		fmt.Sprintf("# numResponses: %d", w.entitiesWritten+1),
+		fmt.Sprintf("# numEntries: %d", w.entitiesWritten),
+	}
+
+	for _, line := range trailerLines {
+		w.writeLine(line)
+	}
+
+	return w.err
+}
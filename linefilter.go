@@ -0,0 +1,68 @@
+package ldifparser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser/entitybuilder"
+)
+
+// lineFilter applies an AttributeFilter to raw LDIF lines so that the
+// values of excluded attributes are never copied out of the scan buffer
+// or decoded. Decisions are cached by attribute name. A nil lineFilter
+// does not filter any lines.
+type lineFilter struct {
+	filter entitybuilder.AttributeFilter
+	keep   []string
+	cache  map[string]bool
+}
+
+// newLineFilter returns nil if `filter` would not exclude any attributes.
+// The attributes named in `keep` are never excluded.
+func newLineFilter(filter entitybuilder.AttributeFilter, keep ...string) *lineFilter {
+	if filter == nil || filter.IsEmpty() {
+		return nil
+	}
+
+	return &lineFilter{
+		filter: filter,
+		keep:   append([]string{"dn", "distinguishedName"}, keep...),
+		cache:  make(map[string]bool),
+	}
+}
+
+// isFiltered returns true if the attribute on `line` should be excluded.
+// The DN and kept attributes are never excluded, and malformed lines are
+// left for the entity builder to report.
+func (lf *lineFilter) isFiltered(line []byte) bool {
+	if lf == nil {
+		return false
+	}
+
+	sepIdx := bytes.IndexByte(line, ':')
+	if sepIdx < 1 {
+		return false
+	}
+
+	name := line[:sepIdx]
+	if filtered, found := lf.cache[string(name)]; found {
+		return filtered
+	}
+
+	nameStr := string(name)
+	filtered := !lf.isKept(nameStr) && lf.filter.IsFiltered(entity.Attribute{Name: nameStr})
+
+	lf.cache[nameStr] = filtered
+	return filtered
+}
+
+func (lf *lineFilter) isKept(name string) bool {
+	for _, keptName := range lf.keep {
+		if strings.EqualFold(name, keptName) {
+			return true
+		}
+	}
+
+	return false
+}
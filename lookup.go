@@ -0,0 +1,637 @@
+package ldifparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser/entitybuilder"
+	"github.com/kgoins/ldifparser/syntax"
+)
+
+// ErrAmbiguousKey is returned by ReadEntity when ErrOnAmbiguousKey
+// is set and more than one entity matches the lookup key.
+var ErrAmbiguousKey = merry.New("lookup key matches more than one entity")
+
+// errStopScan stops a key scan without reporting an error
+var errStopScan = merry.New("stop scanning")
+
+// KeyMatchRule determines how lookup keys are compared
+// with the decoded values of the key attribute.
+type KeyMatchRule int
+
+const (
+	// KeyMatchCaseIgnore compares values ignoring case and insignificant
+	// spaces, like the LDAP caseIgnoreMatch rule. DN values are compared
+	// by their RDN components.
+	KeyMatchCaseIgnore KeyMatchRule = iota
+	// KeyMatchExact compares values byte for byte.
+	KeyMatchExact
+	// KeyMatchBinary compares values byte for byte with keys that are
+	// base64 encoded, as binary values such as objectGUID are in LDIF.
+	KeyMatchBinary
+)
+
+// keyMatcher matches the attribute lines of entities against one or more
+// lookup values. Attribute names are compared case-insensitively, dn and
+// distinguishedName are interchangeable, and base64 values are decoded
+// before they are compared using the KeyMatchRule.
+type keyMatcher struct {
+	names []string
+	isDN  bool
+	rule  KeyMatchRule
+	keys  map[string][]string
+}
+
+func newKeyMatcher(keyAttrName string, rule KeyMatchRule, keyAttrVals ...string) (keyMatcher, error) {
+	matcher := keyMatcher{
+		names: []string{keyAttrName},
+		rule:  rule,
+		keys:  make(map[string][]string, len(keyAttrVals)),
+	}
+
+	if strings.EqualFold(keyAttrName, "dn") || strings.EqualFold(keyAttrName, "distinguishedName") {
+		matcher.names = []string{"dn", "distinguishedName"}
+		matcher.isDN = true
+	}
+
+	for _, val := range keyAttrVals {
+		keyVal := val
+		if rule == KeyMatchBinary {
+			decoded, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return keyMatcher{}, merry.Wrap(err, merry.AppendMessagef("invalid binary key: %s", val))
+			}
+			keyVal = string(decoded)
+		}
+
+		key := matcher.normalize(keyVal)
+		matcher.keys[key] = append(matcher.keys[key], val)
+	}
+
+	return matcher, nil
+}
+
+// normalize returns the form of a decoded value that is compared.
+func (km keyMatcher) normalize(val string) string {
+	if km.rule != KeyMatchCaseIgnore {
+		return val
+	}
+
+	if km.isDN {
+		dn, err := syntax.ParseDN(val)
+		if err == nil {
+			return dn.CanonicalString()
+		}
+	}
+
+	if isCaseIgnoreNormal(val) {
+		return val
+	}
+
+	return strings.ToLower(strings.Join(strings.Fields(val), " "))
+}
+
+// isCaseIgnoreNormal returns true if `val` is ASCII without
+// uppercase letters or insignificant spaces, and so would
+// not be changed by case-ignore normalization.
+func isCaseIgnoreNormal(val string) bool {
+	if val == "" || val[0] == ' ' || val[len(val)-1] == ' ' {
+		return val == ""
+	}
+
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		if c >= utf8.RuneSelf || c < ' ' || ('A' <= c && c <= 'Z') {
+			return false
+		}
+		if c == ' ' && val[i-1] == ' ' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (km keyMatcher) isKeyAttribute(name string) bool {
+	for _, keyName := range km.names {
+		if strings.EqualFold(name, keyName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchLine returns the lookup values matched by an unfolded line.
+func (km keyMatcher) matchLine(line string) []string {
+	sepIdx := strings.IndexByte(line, ':')
+	if sepIdx < 1 || syntax.IsLdifComment(line) || !km.isKeyAttribute(line[:sepIdx]) {
+		return nil
+	}
+
+	_, val, err := entitybuilder.ParseAttributeLine(line)
+	if err != nil {
+		return nil
+	}
+
+	value := val.Value
+	if val.Encoding != entitybuilder.PlainEncoding {
+		decoded, err := val.Decode()
+		if err != nil {
+			return nil
+		}
+		value = string(decoded)
+	}
+
+	return km.keys[km.normalize(value)]
+}
+
+// matchBlock returns the lookup values matched by any line of an entity block.
+func (km keyMatcher) matchBlock(entityLines []string) []string {
+	matched := []string{}
+	for _, line := range entityLines {
+		matched = append(matched, km.matchLine(line)...)
+	}
+
+	return matched
+}
+
+// numValues returns the number of distinct lookup values.
+func (km keyMatcher) numValues() int {
+	numVals := 0
+	for _, vals := range km.keys {
+		numVals += len(vals)
+	}
+
+	return numVals
+}
+
+func (km keyMatcher) String() string {
+	vals := []string{}
+	for _, keyVals := range km.keys {
+		vals = append(vals, keyVals...)
+	}
+
+	return fmt.Sprintf("%s=%q", km.names[0], vals)
+}
+
+// scanKeyMatches calls `handleMatch` with each entity block that matches
+// a lookup value. Only the key attribute is parsed for entities that do
+// not match. Scanning stops at the first error returned by `handleMatch`,
+// which is returned unless it is errStopScan. Lookups always cover the
+// whole input, ignoring ResumeFrom.
+func (r LdifReader) scanKeyMatches(matcher keyMatcher, handleMatch func(block entityBlock, matched []string) error) error {
+	r.ResumeFrom = ResumeToken{}
+	scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+	if errors.Is(err, errNoEntityBlock) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	keyFilter := newLineFilter(entitybuilder.NewAttributeFilter(matcher.names...))
+
+	handleBlock := func(block entityBlock, blockErr error) bool {
+		if blockErr != nil {
+			err = blockErr
+			return false
+		}
+
+		matched := matcher.matchBlock(block.lines)
+		if len(matched) == 0 {
+			return true
+		}
+
+		// read the whole entity now that it is known to match
+		entityBlock, blockErr := r.rereadBlock(block)
+		if blockErr != nil {
+			err = blockErr
+			return false
+		}
+
+		err = handleMatch(entityBlock, matched)
+		return err == nil
+	}
+
+	data, inMemory := r.inputBytes()
+	if needle, ignoreCase, canSearch := matcher.byteNeedle(); inMemory && canSearch {
+		search := newKeySearch(data, matcher, needle, ignoreCase)
+		r.scanCandidateBlocks(data, blockPos, firstLine, search, keyFilter, handleBlock)
+	} else {
+		r.scanBlocks(scanner, blockPos, firstLine, keyFilter, handleBlock)
+	}
+
+	if err == errStopScan {
+		return nil
+	}
+
+	return err
+}
+
+// byteNeedle returns the bytes that every plain, unfolded value matching
+// the lookup value contains, so that in-memory inputs can be searched for
+// them instead of parsing every entity. The needle is lowercase when it
+// must be compared ignoring ASCII case. It returns false for lookups of
+// many values, and for values that normalization could change.
+func (km keyMatcher) byteNeedle() (needle []byte, ignoreCase bool, canSearch bool) {
+	if len(km.keys) != 1 {
+		return nil, false, false
+	}
+
+	var key string
+	for normalized := range km.keys {
+		key = normalized
+	}
+	if key == "" {
+		return nil, false, false
+	}
+
+	if km.rule != KeyMatchCaseIgnore {
+		return []byte(key), false, true
+	}
+
+	if km.isDN || strings.Contains(key, " ") || !isCaseIgnoreNormal(key) {
+		return nil, false, false
+	}
+
+	return []byte(key), true, true
+}
+
+// scanCandidateBlocks calls `handleBlock` with the entity blocks of the
+// in-memory input `data` that could match the lookup value, starting at
+// offset `pos`, whose line number is `line`. All other blocks are skipped
+// without being parsed.
+func (r LdifReader) scanCandidateBlocks(
+	data []byte, pos int64, line int, search *keySearch,
+	filter *lineFilter, handleBlock func(block entityBlock, err error) bool,
+) {
+	for {
+		candidate := search.nextCandidate(pos)
+		if candidate < 0 {
+			return
+		}
+
+		start := blockStartBefore(data, candidate, pos)
+		line += bytes.Count(data[pos:start], []byte{'\n'})
+
+		// scan the candidate's block, or the first entity
+		// block after it if the candidate isn't in an entity
+		isScanned := false
+		shouldContinue := true
+		r.scanBlocks(r.newBytesScanner(data, start), start, line, filter, func(block entityBlock, err error) bool {
+			isScanned = true
+			shouldContinue = handleBlock(block, err)
+			pos, line = block.end, block.nextLine
+			return false
+		})
+
+		if !isScanned || !shouldContinue {
+			return
+		}
+	}
+}
+
+// keySearch finds the candidates of scanCandidateBlocks: the positions
+// of `needle`, and of the key attribute lines whose values are base64
+// encoded or folded, which are only matched by parsing. The next position
+// of each kind of candidate is kept, so each byte of the input is searched
+// at most once per kind.
+type keySearch struct {
+	data       []byte
+	km         keyMatcher
+	needle     []byte
+	ignoreCase bool
+	next       [3]int64
+}
+
+func newKeySearch(data []byte, km keyMatcher, needle []byte, ignoreCase bool) *keySearch {
+	return &keySearch{
+		data:       data,
+		km:         km,
+		needle:     needle,
+		ignoreCase: ignoreCase,
+		next:       [3]int64{-1, -1, -1},
+	}
+}
+
+// nextCandidate returns the offset of the first candidate
+// at or after `from`, or -1 if there is none.
+func (ks *keySearch) nextCandidate(from int64) int64 {
+	end := int64(len(ks.data))
+	finders := [3]func(from int64) int64{ks.nextNeedle, ks.nextBase64KeyLine, ks.nextFoldedKeyLine}
+
+	first := end
+	for kind, find := range finders {
+		if ks.next[kind] < from {
+			ks.next[kind] = find(from)
+		}
+		if ks.next[kind] < first {
+			first = ks.next[kind]
+		}
+	}
+
+	if first == end {
+		return -1
+	}
+	return first
+}
+
+// nextNeedle returns the offset of the first `needle` at or
+// after `from`, or the end of the input if there is none.
+func (ks *keySearch) nextNeedle(from int64) int64 {
+	var idx int
+	if ks.ignoreCase {
+		idx = indexFoldASCII(ks.data[from:], ks.needle)
+	} else {
+		idx = bytes.Index(ks.data[from:], ks.needle)
+	}
+
+	if idx < 0 {
+		return int64(len(ks.data))
+	}
+	return from + int64(idx)
+}
+
+// nextBase64KeyLine returns the offset of the separator of the first base64
+// key attribute line at or after `from`, or the end of the input.
+func (ks *keySearch) nextBase64KeyLine(from int64) int64 {
+	for pos := from; ; {
+		idx := bytes.Index(ks.data[pos:], []byte("::"))
+		if idx < 0 {
+			return int64(len(ks.data))
+		}
+
+		sep := pos + int64(idx)
+		if ks.km.isKeyAttribute(string(ks.data[lineStart(ks.data, sep):sep])) {
+			return sep
+		}
+		pos = sep + 2
+	}
+}
+
+// nextFoldedKeyLine returns the offset of the first line ending that is
+// followed by a continuation of a key attribute line, at or after `from`,
+// or the end of the input.
+func (ks *keySearch) nextFoldedKeyLine(from int64) int64 {
+	data := ks.data
+
+	for pos := from; ; {
+		idx := bytes.Index(data[pos:], []byte("\n "))
+		if idx < 0 {
+			return int64(len(data))
+		}
+
+		// find the line that the continuation belongs to
+		fold := pos + int64(idx)
+		start := lineStart(data, fold)
+		for start > 0 && data[start] == ' ' {
+			start = lineStart(data, start-1)
+		}
+
+		sepIdx := bytes.IndexByte(data[start:fold], ':')
+		if sepIdx > 0 && ks.km.isKeyAttribute(string(data[start:start+int64(sepIdx)])) {
+			return fold
+		}
+
+		// skip the rest of the folded line
+		pos = fold + 1
+		for {
+			lineEnd := bytes.IndexByte(data[pos:], '\n')
+			if lineEnd < 0 || pos+int64(lineEnd)+1 >= int64(len(data)) || data[pos+int64(lineEnd)+1] != ' ' {
+				break
+			}
+			pos += int64(lineEnd) + 1
+		}
+	}
+}
+
+// lineStart returns the offset of the start of the line holding `offset`.
+func lineStart(data []byte, offset int64) int64 {
+	return int64(bytes.LastIndexByte(data[:offset], '\n') + 1)
+}
+
+// blockStartBefore returns the offset of the first line of the block
+// holding `offset`, which is never before `floor`, the start of a line.
+func blockStartBefore(data []byte, offset int64, floor int64) int64 {
+	start := lineStart(data, offset)
+	for start > floor {
+		prevStart := lineStart(data, start-1)
+		if len(bytes.TrimSpace(data[prevStart:start])) == 0 {
+			return start
+		}
+		start = prevStart
+	}
+
+	return floor
+}
+
+// indexFoldASCII is bytes.Index for a lowercase `needle`
+// that matches `data` regardless of ASCII case.
+func indexFoldASCII(data []byte, needle []byte) int {
+	lower := needle[0]
+	upper := lower
+	if 'a' <= lower && lower <= 'z' {
+		upper -= 'a' - 'A'
+	}
+
+	// the next position of each case of the first byte
+	nextLower, nextUpper := -1, -1
+	if lower == upper {
+		nextUpper = len(data)
+	}
+
+	for pos := 0; pos+len(needle) <= len(data); {
+		if nextLower < pos {
+			nextLower = indexByteFrom(data, lower, pos)
+		}
+		if nextUpper < pos {
+			nextUpper = indexByteFrom(data, upper, pos)
+		}
+
+		hit := nextLower
+		if nextUpper < hit {
+			hit = nextUpper
+		}
+		if hit+len(needle) > len(data) {
+			return -1
+		}
+
+		if bytes.EqualFold(data[hit:hit+len(needle)], needle) {
+			return hit
+		}
+		pos = hit + 1
+	}
+
+	return -1
+}
+
+// indexByteFrom returns the offset of the first `c` at or
+// after `from`, or the length of `data` if there is none.
+func indexByteFrom(data []byte, c byte, from int) int {
+	idx := bytes.IndexByte(data[from:], c)
+	if idx < 0 {
+		return len(data)
+	}
+	return from + idx
+}
+
+// ReadEntitiesByKeys looks up the entities for many key values in a single
+// pass over the input. Keys are matched the same way as in ReadEntity, and
+// each key is mapped to the first entity that matches it. Keys that match
+// no entity are returned in the order they were given.
+func (r LdifReader) ReadEntitiesByKeys(keyAttrName string, keyAttrVals []string) (map[string]entity.Entity, []string, error) {
+	matcher, err := newKeyMatcher(keyAttrName, r.KeyMatchRule, dedupe(keyAttrVals)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Logger.Info("searching with %d keys", matcher.numValues())
+
+	found := make(map[string]entity.Entity, matcher.numValues())
+
+	err = r.scanKeyMatches(matcher, func(block entityBlock, matched []string) error {
+		unresolved := []string{}
+		for _, val := range matched {
+			if _, isFound := found[val]; !isFound {
+				unresolved = append(unresolved, val)
+			}
+		}
+
+		if len(unresolved) == 0 {
+			return nil
+		}
+
+		e, err := r.readSingleEntity(block.lines)
+		if err != nil {
+			return err
+		}
+
+		for _, val := range unresolved {
+			found[val] = e
+		}
+
+		if len(found) == matcher.numValues() {
+			return errStopScan
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notFound := []string{}
+	for _, val := range keyAttrVals {
+		if _, isFound := found[val]; !isFound {
+			notFound = append(notFound, val)
+		}
+	}
+
+	return found, dedupe(notFound), nil
+}
+
+// dedupe returns `vals` without repeated values, keeping their order.
+func dedupe(vals []string) []string {
+	seen := make(map[string]bool, len(vals))
+	unique := make([]string, 0, len(vals))
+
+	for _, val := range vals {
+		if !seen[val] {
+			seen[val] = true
+			unique = append(unique, val)
+		}
+	}
+
+	return unique
+}
+
+// ReadEntitiesByKey returns every entity that matches the key, unlike
+// ReadEntity which returns only the first. Keys are matched the same way
+// as in ReadEntity.
+func (r LdifReader) ReadEntitiesByKey(keyAttrName string, keyAttrVal string) []EntityResp {
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	entities := []EntityResp{}
+	for resp := range r.ReadEntitiesByKeyChanneled(keyAttrName, keyAttrVal, interrupt) {
+		entities = append(entities, resp)
+	}
+
+	return entities
+}
+
+// ReadEntitiesByKeyChanneled streams every entity that matches the key over
+// the returned channel. Errors and `interrupt` are handled the same way as in
+// ReadEntitiesChanneled, though only errors from matching entities and read
+// errors are reported.
+func (r LdifReader) ReadEntitiesByKeyChanneled(keyAttrName string, keyAttrVal string, interrupt <-chan bool) <-chan EntityResp {
+	results := make(chan EntityResp)
+
+	go func() {
+		defer close(results)
+
+		send := func(resp EntityResp) bool {
+			select {
+			case results <- resp:
+				return true
+			case <-interrupt:
+				return false
+			}
+		}
+
+		matcher, err := newKeyMatcher(keyAttrName, r.KeyMatchRule, keyAttrVal)
+		if err != nil {
+			send(EntityResp{Error: err, Meta: EntityMeta{Source: r.SourceName}})
+			return
+		}
+
+		err = r.scanKeyMatches(matcher, func(block entityBlock, matched []string) error {
+			resp := r.buildEntityResp(block, nil)
+			if !send(resp) || (resp.Error != nil && !r.ContinueOnErr) {
+				return errStopScan
+			}
+			return nil
+		})
+
+		if err != nil {
+			send(EntityResp{Error: err, Meta: EntityMeta{Source: r.SourceName}})
+		}
+	}()
+
+	return results
+}
+
+// ReadEntityByGUID returns the entity whose objectGUID is `guid`, written in
+// its string form, ex) {0f5fe0ec-275d-4442-9561-8f141c257669}. The
+// conversion to the binary value stored in the input is handled here.
+func (r LdifReader) ReadEntityByGUID(guid string) (entity.Entity, error) {
+	guidBytes, err := syntax.ParseGUID(guid)
+	if err != nil {
+		return entity.Entity{}, merry.Wrap(err)
+	}
+
+	return r.readEntityByBinaryKey("objectGUID", guidBytes)
+}
+
+// ReadEntityBySID returns the entity whose objectSid is `sid`, written in
+// its string form, ex) S-1-5-21-73586283-854245398-1428897155-795234.
+func (r LdifReader) ReadEntityBySID(sid string) (entity.Entity, error) {
+	sidBytes, err := syntax.ParseSID(sid)
+	if err != nil {
+		return entity.Entity{}, merry.Wrap(err)
+	}
+
+	return r.readEntityByBinaryKey("objectSid", sidBytes)
+}
+
+// readEntityByBinaryKey looks up a binary key regardless of the reader's KeyMatchRule
+func (r LdifReader) readEntityByBinaryKey(keyAttrName string, key []byte) (entity.Entity, error) {
+	r.KeyMatchRule = KeyMatchBinary
+	return r.ReadEntity(keyAttrName, base64.StdEncoding.EncodeToString(key))
+}
@@ -0,0 +1,278 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/kgoins/ldifparser/entitybuilder"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup_ReadEntitiesByKeys(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+	keys := []string{"MYUSR", "NOSUCHUSER", "disableduser", "MYUSR"}
+
+	found, notFound, err := ldifReader.ReadEntitiesByKeys("sAMAccountName", keys)
+	r.NoError(err)
+
+	r.Len(found, 2)
+	r.Equal([]string{"NOSUCHUSER"}, notFound)
+
+	for key, expected := range map[string]string{"MYUSR": "MYUSR", "disableduser": "DISABLEDUSER"} {
+		e, isFound := found[key]
+		r.True(isFound, key)
+
+		name, _ := e.GetSingleValuedAttribute("sAMAccountName")
+		r.Equal(expected, name)
+	}
+}
+
+func TestLookup_ReadEntitiesByKeys_FilteredKey(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+	ldifReader.SetAttributeFilter(entitybuilder.NewAttributeFilter("cn"))
+
+	found, notFound, err := ldifReader.ReadEntitiesByKeys("sAMAccountName", []string{"MYUSR"})
+	r.NoError(err)
+	r.Empty(notFound)
+
+	e := found["MYUSR"]
+	r.Equal(2, e.Size())
+
+	_, hasKey := e.GetAttribute("sAMAccountName")
+	r.False(hasKey)
+}
+
+func TestLookup_ReadEntitiesByKeys_ManyKeys(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(generateLdif(1000, 16)))
+
+	keys := []string{}
+	for i := 0; i < 1200; i += 3 {
+		keys = append(keys, fmt.Sprintf("user%d", i))
+	}
+
+	found, notFound, err := ldifReader.ReadEntitiesByKeys("cn", keys)
+	r.NoError(err)
+	r.Len(found, 334)
+	r.Len(notFound, 66)
+	r.Equal("user1002", notFound[0])
+
+	dn, _ := found["user999"].GetDN()
+	r.Equal("CN=user999,OU=Users,DC=contoso,DC=com", dn)
+}
+
+func BenchmarkLookup_ReadEntitiesByKeys(b *testing.B) {
+	ldif := generateLdif(10000, 16)
+
+	keys := []string{}
+	for i := 0; i < 10000; i += 20 {
+		keys = append(keys, fmt.Sprintf("user%d", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		reader := ldifparser.NewLdifReader(bytes.NewReader(ldif))
+		_, notFound, err := reader.ReadEntitiesByKeys("sAMAccountName", keys)
+		if err != nil || len(notFound) > 0 {
+			b.Fatal(err, notFound)
+		}
+	}
+}
+
+func TestLookup_ReadEntitiesByKey(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+
+	results := ldifReader.ReadEntitiesByKey("memberOf", "CN=vault_users,OU=Global,OU=Security,OU=Groups,DC=contoso,DC=com")
+	r.Len(results, numTestFileEntities)
+
+	names := []string{}
+	lines := []int{}
+	for _, resp := range results {
+		r.NoError(resp.Error)
+
+		name, _ := resp.Entity.GetSingleValuedAttribute("sAMAccountName")
+		names = append(names, name)
+		lines = append(lines, resp.Meta.Line)
+	}
+	r.Equal([]string{"MYUSR", "DISABLEDUSER", "MYPC"}, names)
+	r.Equal([]int{10, 49, 88}, lines)
+
+	r.Empty(ldifReader.ReadEntitiesByKey("memberOf", "CN=NoSuchGroup,DC=contoso,DC=com"))
+}
+
+func TestLookup_ReadEntitiesByKeyInterrupt(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(generateLdif(100, 16)))
+
+	interrupt := make(chan bool)
+	results := ldifReader.ReadEntitiesByKeyChanneled("objectClass", "user", interrupt)
+
+	resp := <-results
+	r.NoError(resp.Error)
+	close(interrupt)
+
+	for range results {
+	}
+}
+
+func TestLookup_ErrOnAmbiguousKey(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewReaderConf()
+	conf.ErrOnAmbiguousKey = true
+
+	ldifReader := openTestReader(t, testFileName)
+	ldifReader.ReaderConf = conf
+
+	_, err := ldifReader.ReadEntity("instanceType", "4")
+	r.ErrorIs(err, ldifparser.ErrAmbiguousKey)
+
+	e, err := ldifReader.ReadEntity("sAMAccountName", "MYPC")
+	r.NoError(err)
+	r.False(e.IsEmpty())
+
+	// without the option the first match is returned
+	ldifReader.ErrOnAmbiguousKey = false
+	e, err = ldifReader.ReadEntity("instanceType", "4")
+	r.NoError(err)
+
+	name, _ := e.GetSingleValuedAttribute("sAMAccountName")
+	r.Equal("MYUSR", name)
+}
+
+var keyMatchTestLdif = strings.Join([]string{
+	"version: 1",
+	"",
+	"dn: CN=Jöhn Smith,OU=Users,DC=contoso,DC=com",
+	"cn:: " + base64.StdEncoding.EncodeToString([]byte("Jöhn Smith")),
+	"description: a description that is folded",
+	"  across two lines",
+	"objectGUID:: 7OBfD10nQkSVYY8UHCV2aQ==",
+	"sAMAccountName:   jsmith",
+	"",
+	"# untitled entity without a title line",
+	"distinguishedName: CN=Svc,OU=Users,DC=contoso,DC=com",
+	"sAMAccountName: SVC",
+	"",
+}, "\n")
+
+func readKeyMatchTestEntity(t *testing.T, rule ldifparser.KeyMatchRule, keyAttrName string, keyAttrVal string) string {
+	conf := ldifparser.NewReaderConf()
+	conf.KeyMatchRule = rule
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(keyMatchTestLdif), conf)
+
+	e, err := ldifReader.ReadEntity(keyAttrName, keyAttrVal)
+	require.NoError(t, err)
+
+	dn, _ := e.GetDN()
+	return dn
+}
+
+func TestLookup_KeyMatchCaseIgnore(t *testing.T) {
+	r := require.New(t)
+
+	johnDN := "CN=Jöhn Smith,OU=Users,DC=contoso,DC=com"
+	svcDN := "CN=Svc,OU=Users,DC=contoso,DC=com"
+
+	testMap := map[[2]string]string{
+		{"CN", "jöhn  smith"}: johnDN,
+		{"description", "A description that is folded across two lines"}:   johnDN,
+		{"samaccountname", "JSMITH"}:                                       johnDN,
+		{"distinguishedName", "cn=jöhn smith, ou=users,dc=contoso,dc=com"}: johnDN,
+		{"dn", svcDN}:                    svcDN,
+		{"sAMAccountName", "svc"}:        svcDN,
+		{"sAMAccountName", "nosuchuser"}: "",
+	}
+
+	for key, expectedDN := range testMap {
+		r.Equal(expectedDN, readKeyMatchTestEntity(t, ldifparser.KeyMatchCaseIgnore, key[0], key[1]), key)
+	}
+}
+
+func TestLookup_KeyMatchExact(t *testing.T) {
+	r := require.New(t)
+
+	r.Empty(readKeyMatchTestEntity(t, ldifparser.KeyMatchExact, "sAMAccountName", "svc"))
+	r.NotEmpty(readKeyMatchTestEntity(t, ldifparser.KeyMatchExact, "sAMAccountName", "SVC"))
+	r.NotEmpty(readKeyMatchTestEntity(t, ldifparser.KeyMatchExact, "cn", "Jöhn Smith"))
+}
+
+func TestLookup_KeyMatchBinary(t *testing.T) {
+	r := require.New(t)
+
+	r.NotEmpty(readKeyMatchTestEntity(t, ldifparser.KeyMatchBinary, "objectGUID", "7OBfD10nQkSVYY8UHCV2aQ=="))
+	r.Empty(readKeyMatchTestEntity(t, ldifparser.KeyMatchBinary, "objectGUID", "AAAAAAAAAAAAAAAAAAAAAA=="))
+
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(keyMatchTestLdif))
+	ldifReader.KeyMatchRule = ldifparser.KeyMatchBinary
+
+	_, err := ldifReader.ReadEntity("objectGUID", "not base64!")
+	r.Error(err)
+}
+
+func TestLookup_ReadEntityByGUIDAndSID(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+
+	e, err := ldifReader.ReadEntityByGUID("{0F5FE0EC-275D-4442-9561-8F141C257669}")
+	r.NoError(err)
+
+	cn, _ := e.GetSingleValuedAttribute("cn")
+	r.Equal("MYUSR", cn)
+
+	e, err = ldifReader.ReadEntityBySID("S-1-5-21-73586283-854245398-1428897155-795234")
+	r.NoError(err)
+
+	cn, _ = e.GetSingleValuedAttribute("cn")
+	r.Equal("MYUSR", cn)
+
+	// unaffected by the reader's own key matching rule
+	conf := ldifparser.NewReaderConf()
+	conf.KeyMatchRule = ldifparser.KeyMatchExact
+	e, err = ldifparser.NewLdifReader(strings.NewReader(keyMatchTestLdif), conf).
+		ReadEntityByGUID("0f5fe0ec-275d-4442-9561-8f141c257669")
+	r.NoError(err)
+	r.False(e.IsEmpty())
+
+	e, err = ldifReader.ReadEntityBySID("S-1-5-21-73586283-854245398-1428897155-1")
+	r.NoError(err)
+	r.True(e.IsEmpty())
+
+	_, err = ldifReader.ReadEntityByGUID("not-a-guid")
+	r.Error(err)
+
+	_, err = ldifReader.ReadEntityBySID("S-1-5-x")
+	r.Error(err)
+}
+
+func TestLookup_ReadEntityAfterDNlessRecord(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(dnlessRecordLdif))
+
+	e, err := ldifReader.ReadEntity("cn", "USR2")
+	r.NoError(err)
+
+	dn, _ := e.GetDN()
+	r.Equal("CN=USR2,DC=contoso,DC=com", dn)
+
+	found, notFound, err := ldifReader.ReadEntitiesByKeys("sAMAccountName", []string{"USR1", "USR2"})
+	r.NoError(err)
+	r.Len(found, 2)
+	r.Empty(notFound)
+}
@@ -0,0 +1,88 @@
+package ldifparser
+
+import (
+	"bytes"
+	"os"
+	"unsafe"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// byteSource is implemented by inputs that are held in memory, such
+// as an MmapFile. Readers scan these inputs directly instead of
+// copying them through a scan buffer.
+type byteSource interface {
+	Bytes() []byte
+}
+
+// MmapFile is a read-only, memory mapped file that implements
+// ReadSeekerAt so that it can be used as the input of an LdifReader.
+// Lookups of a single key on an MmapFile use a fast byte search across the
+// mapped region to jump to the entities that could match, and scans return
+// lines without copying them through a scan buffer.
+// Memory mapping is only supported on Linux.
+type MmapFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+// OpenMmapFile maps the file at `path` into memory.
+// The file must be closed with Close once it is no longer used.
+func OpenMmapFile(path string) (*MmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if int64(int(size)) != size {
+		return nil, merry.Errorf("file is too large to map: %d bytes", size)
+	}
+
+	// empty files can't be mapped
+	data := []byte{}
+	if size > 0 {
+		data, err = mmap(f, int(size))
+		if err != nil {
+			return nil, merry.Wrap(err, merry.AppendMessagef("unable to map file %s", path))
+		}
+	}
+
+	return &MmapFile{
+		Reader: bytes.NewReader(data),
+		data:   data,
+	}, nil
+}
+
+// Bytes returns the mapped contents of the file.
+// The slice must not be used after the file is closed.
+func (m *MmapFile) Bytes() []byte {
+	return m.data
+}
+
+// Close unmaps the file. Entities read with ReaderConf.ZeroCopy
+// set must not be used after the file is closed, and Close must
+// not be called while the file is being read.
+func (m *MmapFile) Close() error {
+	data := m.data
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return munmap(data)
+}
+
+// unsafeString returns a string that shares memory with `b`,
+// which must not be modified while the string is in use.
+func unsafeString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
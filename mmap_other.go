@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package ldifparser
+
+import (
+	"os"
+
+	"github.com/ansel1/merry/v2"
+)
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, merry.New("memory mapped files are only supported on linux")
+}
+
+func munmap(data []byte) error {
+	return nil
+}
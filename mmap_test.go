@@ -0,0 +1,267 @@
+//go:build linux
+// +build linux
+
+package ldifparser_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestMmapFile(t *testing.T, filePath string) *ldifparser.MmapFile {
+	mmapFile, err := ldifparser.OpenMmapFile(filePath)
+	require.NoError(t, err)
+	t.Cleanup(func() { mmapFile.Close() })
+
+	return mmapFile
+}
+
+func writeTempLdif(t *testing.T, ldif string) string {
+	filePath := filepath.Join(t.TempDir(), "test.ldif")
+	require.NoError(t, ioutil.WriteFile(filePath, []byte(ldif), 0600))
+
+	return filePath
+}
+
+func TestMmap_ReadEntity(t *testing.T) {
+	r := require.New(t)
+
+	mmapFile := openTestMmapFile(t, filepath.Join(getTestDataDir(), testFileName))
+	ldifReader := ldifparser.NewLdifReader(mmapFile)
+
+	// repeated lookups don't depend on the seek position
+	for _, name := range []string{"DISABLEDUSER", "MYUSR", "disableduser"} {
+		e, err := ldifReader.ReadEntity("sAMAccountName", name)
+		r.NoError(err)
+		r.False(e.IsEmpty(), name)
+
+		eName, found := e.GetSingleValuedAttribute("sAMAccountName")
+		r.True(found)
+		r.True(strings.EqualFold(name, eName))
+	}
+
+	e, err := ldifReader.ReadEntity("sAMAccountName", "NOSUCHUSER")
+	r.NoError(err)
+	r.True(e.IsEmpty())
+}
+
+func TestMmap_ReadEntities(t *testing.T) {
+	r := require.New(t)
+
+	mmapFile := openTestMmapFile(t, filepath.Join(getTestDataDir(), testFileName))
+
+	expected := readAllDNs(t, openTestReader(t, testFileName))
+	actual := readAllDNs(t, ldifparser.NewLdifReader(mmapFile))
+	r.Equal(expected, actual)
+}
+
+func TestMmap_ZeroCopy(t *testing.T) {
+	r := require.New(t)
+
+	filePath := writeTempLdif(t, strings.Join([]string{
+		"dn: CN=USR1,DC=contoso,DC=com",
+		"cn: USR1",
+		"description: folded",
+		"  value",
+		"",
+		"dn: CN=USR2,DC=contoso,DC=com",
+		"cn: USR2",
+		"",
+	}, "\r\n"))
+	mmapFile := openTestMmapFile(t, filePath)
+
+	conf := ldifparser.NewReaderConf()
+	conf.ZeroCopy = true
+	entities := ldifparser.NewLdifReader(mmapFile, conf).ReadEntities()
+	r.Len(entities, 2)
+
+	cn, _ := entities[0].Entity.GetSingleValuedAttribute("cn")
+	r.Equal("USR1", cn)
+
+	desc, _ := entities[0].Entity.GetSingleValuedAttribute("description")
+	r.Equal("folded value", desc)
+
+	dn, _ := entities[1].Entity.GetDN()
+	r.Equal("CN=USR2,DC=contoso,DC=com", dn)
+}
+
+func TestMmap_Parallel(t *testing.T) {
+	r := require.New(t)
+
+	ldif := generateLdif(400, 2048)
+	mmapFile := openTestMmapFile(t, writeTempLdif(t, string(ldif)))
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	conf.ZeroCopy = true
+
+	expected := readAllDNs(t, ldifparser.NewLdifReader(bytes.NewReader(ldif)))
+	r.Equal(expected, readAllDNs(t, ldifparser.NewLdifReader(mmapFile, conf)))
+}
+
+func TestMmap_LineTooLong(t *testing.T) {
+	r := require.New(t)
+
+	mmapFile := openTestMmapFile(t, filepath.Join(getTestDataDir(), "hugeattr.ldif"))
+
+	_, err := ldifparser.NewLdifReader(mmapFile).ReadEntity("cn", "MYUSR")
+	r.ErrorIs(err, bufio.ErrTooLong)
+
+	conf := ldifparser.NewReaderConf()
+	conf.ScannerBufferSize = 1400000
+
+	e, err := ldifparser.NewLdifReader(mmapFile, conf).ReadEntity("cn", "MYUSR")
+	r.NoError(err)
+	r.False(e.IsEmpty())
+}
+
+func TestMmap_EmptyFile(t *testing.T) {
+	r := require.New(t)
+
+	mmapFile := openTestMmapFile(t, writeTempLdif(t, ""))
+	r.Empty(mmapFile.Bytes())
+
+	e, err := ldifparser.NewLdifReader(mmapFile).ReadEntity("cn", "MYUSR")
+	r.NoError(err)
+	r.True(e.IsEmpty())
+
+	r.NoError(mmapFile.Close())
+	r.NoError(mmapFile.Close())
+}
+
+func BenchmarkReadEntity_LastEntity_Mmap(b *testing.B) {
+	ldif := generateLdif(10000, 16)
+	filePath := filepath.Join(b.TempDir(), "bench.ldif")
+	if err := ioutil.WriteFile(filePath, ldif, 0600); err != nil {
+		b.Fatal(err)
+	}
+
+	mmapFile, err := ldifparser.OpenMmapFile(filePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer mmapFile.Close()
+
+	reader := ldifparser.NewLdifReader(mmapFile)
+
+	b.SetBytes(int64(len(ldif)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		e, err := reader.ReadEntity("sAMAccountName", "user9999")
+		if err != nil || e.IsEmpty() {
+			b.Fatal("entity not found", err)
+		}
+	}
+}
+
+func BenchmarkReader_SmallEntities_MmapZeroCopy(b *testing.B) {
+	ldif := generateLdif(10000, 16)
+	filePath := filepath.Join(b.TempDir(), "bench.ldif")
+	if err := ioutil.WriteFile(filePath, ldif, 0600); err != nil {
+		b.Fatal(err)
+	}
+
+	mmapFile, err := ldifparser.OpenMmapFile(filePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer mmapFile.Close()
+
+	conf := ldifparser.NewReaderConf()
+	conf.ZeroCopy = true
+	reader := ldifparser.NewLdifReader(mmapFile, conf)
+
+	b.SetBytes(int64(len(ldif)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, resp := range reader.ReadEntities() {
+			if resp.Error != nil {
+				b.Fatal(resp.Error)
+			}
+		}
+	}
+}
+
+// keySearchLdif holds key values that a byte search of the
+// input can't find directly: encoded, folded and case changed
+const keySearchLdif = `# prologue mentions usr1
+
+dn: CN=USR1,DC=contoso,DC=com
+cn: USR1
+sAMAccountName: usr1
+
+dn: CN=USR2,DC=contoso,DC=com
+cn: USR2
+sAMAccountName:: VXNyMg==
+
+# comment block about usr3
+
+dn: CN=USR3,DC=contoso,
+ DC=com
+cn: USR3
+sAMAccountName: us
+ r3
+
+ref: ldap://usr5/DC=contoso,DC=com
+
+dn: CN=USR5,DC=contoso,DC=com
+cn: USR5
+SAMACCOUNTNAME:   USR5  
+
+dn: CN=USR6,DC=contoso,DC=com
+cn: usr6
+sAMAccountName: other
+
+# search result
+search: 2
+result: 0 Success
+`
+
+func TestMmap_KeySearch(t *testing.T) {
+	r := require.New(t)
+
+	mmapFile := openTestMmapFile(t, writeTempLdif(t, keySearchLdif))
+
+	lookups := []struct {
+		name string
+		rule ldifparser.KeyMatchRule
+		vals []string
+	}{
+		{"sAMAccountName", ldifparser.KeyMatchCaseIgnore, []string{"usr1", "USR2", "usr3", "usr5", "usr6", "other", "success"}},
+		{"sAMAccountName", ldifparser.KeyMatchExact, []string{"usr1", "Usr2", "usr2", "usr3", "USR5  ", "other"}},
+		{"cn", ldifparser.KeyMatchExact, []string{"USR3", "usr6"}},
+		{"dn", ldifparser.KeyMatchExact, []string{"CN=USR3,DC=contoso,DC=com", "CN=USR5,DC=contoso,DC=com"}},
+		{"distinguishedName", ldifparser.KeyMatchCaseIgnore, []string{"cn=usr6, dc=contoso, dc=com"}},
+	}
+
+	for _, lookup := range lookups {
+		conf := ldifparser.NewReaderConf()
+		conf.KeyMatchRule = lookup.rule
+		mmapReader := ldifparser.NewLdifReader(mmapFile, conf)
+		parsingReader := ldifparser.NewLdifReader(strings.NewReader(keySearchLdif), conf)
+
+		for _, val := range lookup.vals {
+			expected := parsingReader.ReadEntitiesByKey(lookup.name, val)
+			actual := mmapReader.ReadEntitiesByKey(lookup.name, val)
+			r.Equal(expected, actual, "%s=%s", lookup.name, val)
+		}
+	}
+
+	// the search finds the same entities as parsing every entity does
+	found := 0
+	for _, val := range []string{"usr1", "usr2", "usr3", "usr5", "other"} {
+		found += len(ldifparser.NewLdifReader(mmapFile).ReadEntitiesByKey("sAMAccountName", val))
+	}
+	r.Equal(5, found)
+}
@@ -0,0 +1,210 @@
+package ldifparser
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/kgoins/ldifparser/entitybuilder"
+)
+
+// indexInterval is the number of entities between the positions kept
+// by an entityIndex. Larger intervals make the index smaller, at the
+// cost of scanning past more entities to reach a page.
+const indexInterval int = 32
+
+// entityIndex maps entity ordinals to their positions in the input.
+// Only the position of every indexInterval-th entity is kept, and the
+// entities between them are skipped by scanning. The index is built on
+// first use and shared by copies of the reader that built it.
+type entityIndex struct {
+	mu        sync.Mutex
+	built     bool
+	count     int
+	positions []ResumeToken
+}
+
+// EntityPage is a page of entities read by ReadEntitiesPage or ReadPageAfter.
+type EntityPage struct {
+	Entities []EntityResp
+
+	// Next is the cursor of the following page, to be passed to
+	// ReadPageAfter, or zero if there are no more entities.
+	Next ResumeToken
+}
+
+// boundaryFilter keeps only the lines needed to tell entity records apart
+// from comments and search results, so records can be skipped cheaply.
+func boundaryFilter() *lineFilter {
+	return newLineFilter(entitybuilder.NewAttributeFilter("dn"), "search", "result")
+}
+
+// getEntityIndex returns the index of the input, building it if needed.
+// The index always covers the whole input, ignoring ResumeFrom.
+func (r LdifReader) getEntityIndex() (*entityIndex, error) {
+	index := r.index
+	if index == nil {
+		index = &entityIndex{}
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	if index.built {
+		return index, nil
+	}
+
+	r.ResumeFrom = ResumeToken{}
+	count := 0
+	positions := []ResumeToken{}
+
+	scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+	switch {
+	case errors.Is(err, errNoEntityBlock):
+		err = nil
+	case err != nil:
+		return nil, err
+	default:
+		r.scanBlocks(scanner, blockPos, firstLine, boundaryFilter(), func(block entityBlock, blockErr error) bool {
+			if blockErr != nil {
+				err = blockErr
+				return false
+			}
+
+			if count%indexInterval == 0 {
+				positions = append(positions, ResumeToken{offset: block.start, line: block.line})
+			}
+			count++
+
+			return true
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.Logger.Info("indexed %d entities", count)
+	index.count = count
+	index.positions = positions
+	index.built = true
+
+	return index, nil
+}
+
+// CountEntities returns the number of entities in the input without
+// parsing them. Malformed entities are counted, so the count matches
+// the number of results of ReadEntities. The first call scans the whole
+// input to build the index used by ReadEntitiesPage.
+func (r LdifReader) CountEntities() (int, error) {
+	index, err := r.getEntityIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	return index.count, nil
+}
+
+// skipEntities returns the position of the entity `numSkipped` entities
+// after the one at `from`, and false if the input ends before it.
+func (r LdifReader) skipEntities(from ResumeToken, numSkipped int) (ResumeToken, bool, error) {
+	r.ResumeFrom = from
+	scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+	if err != nil {
+		return ResumeToken{}, false, err
+	}
+
+	var pos ResumeToken
+	found := false
+	skipped := 0
+
+	r.scanBlocks(scanner, blockPos, firstLine, boundaryFilter(), func(block entityBlock, blockErr error) bool {
+		if blockErr != nil {
+			err = blockErr
+			return false
+		}
+
+		if skipped == numSkipped {
+			pos = ResumeToken{offset: block.start, line: block.line}
+			found = true
+			return false
+		}
+
+		skipped++
+		return true
+	})
+
+	return pos, found, err
+}
+
+// ReadEntitiesPage returns up to `limit` entities, starting with the entity
+// at ordinal `offset`, counting from zero. Ordinals match the order of
+// ReadEntities and always count from the start of the input, regardless of
+// ResumeFrom. An index of entity positions is built on the first call, so
+// that later pages only scan a few entities before the page.
+func (r LdifReader) ReadEntitiesPage(offset int, limit int) (EntityPage, error) {
+	if offset < 0 || limit < 1 {
+		return EntityPage{}, merry.Errorf("invalid page offset %d or limit %d", offset, limit)
+	}
+
+	index, err := r.getEntityIndex()
+	if err != nil {
+		return EntityPage{}, err
+	}
+
+	if offset >= index.count {
+		return EntityPage{Entities: []EntityResp{}}, nil
+	}
+
+	start, found, err := r.skipEntities(index.positions[offset/indexInterval], offset%indexInterval)
+	if err != nil {
+		return EntityPage{}, err
+	}
+	if !found {
+		return EntityPage{}, merry.New("entity index does not match the input")
+	}
+
+	return r.readPage(start, limit), nil
+}
+
+// ReadPageAfter returns up to `limit` entities that follow `cursor`, which
+// is the Next cursor of a previous page or the Resume token of an entity.
+// The zero cursor reads the first page. Pages read this way don't need an
+// index, but can't jump to an arbitrary entity.
+func (r LdifReader) ReadPageAfter(cursor ResumeToken, limit int) (EntityPage, error) {
+	if limit < 1 {
+		return EntityPage{}, merry.Errorf("invalid page limit %d", limit)
+	}
+
+	err := r.checkResumeToken(cursor)
+	if err != nil {
+		return EntityPage{}, err
+	}
+
+	return r.readPage(cursor, limit), nil
+}
+
+// readPage reads up to `limit` entities from `start`. The entity after
+// the page is only looked for, not parsed, to learn if there is a next page.
+func (r LdifReader) readPage(start ResumeToken, limit int) EntityPage {
+	r.ResumeFrom = start
+	page := EntityPage{Entities: []EntityResp{}}
+
+	r.scanEntityBlocks(func(block entityBlock, err error) bool {
+		if errors.Is(err, errNoEntityBlock) {
+			return false
+		}
+
+		if len(page.Entities) == limit {
+			page.Next = page.Entities[limit-1].Resume
+			return false
+		}
+
+		resp := r.buildEntityResp(block, err)
+		page.Entities = append(page.Entities, resp)
+
+		return resp.Error == nil || r.ContinueOnErr
+	})
+
+	return page
+}
@@ -0,0 +1,126 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/kgoins/ldifparser/entitybuilder"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPages_CountEntities(t *testing.T) {
+	r := require.New(t)
+
+	count, err := openTestReader(t, testFileName).CountEntities()
+	r.NoError(err)
+	r.Equal(numTestFileEntities, count)
+
+	count, err = openTestReader(t, "test_users_with_err.ldif").CountEntities()
+	r.NoError(err)
+	r.Equal(3, count)
+
+	count, err = ldifparser.NewLdifReader(strings.NewReader("")).CountEntities()
+	r.NoError(err)
+	r.Zero(count)
+
+	count, err = ldifparser.NewLdifReader(bytes.NewReader(generateLdif(1000, 16))).CountEntities()
+	r.NoError(err)
+	r.Equal(1000, count)
+}
+
+func TestPages_ReadEntitiesPage(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(generateLdif(1000, 16)))
+	entities := ldifReader.ReadEntities()
+
+	for _, offset := range []int{0, 31, 32, 33, 500, 990} {
+		page, err := ldifReader.ReadEntitiesPage(offset, 50)
+		r.NoError(err)
+
+		want := entities[offset:]
+		if len(want) > 50 {
+			want = want[:50]
+		}
+		r.Len(page.Entities, len(want))
+
+		for i, resp := range page.Entities {
+			r.NoError(resp.Error)
+			r.Equal(want[i].Meta, resp.Meta)
+		}
+
+		if offset+50 < len(entities) {
+			r.Equal(want[49].Resume, page.Next)
+		} else {
+			r.True(page.Next.IsZero())
+		}
+	}
+
+	page, err := ldifReader.ReadEntitiesPage(1000, 50)
+	r.NoError(err)
+	r.Empty(page.Entities)
+	r.True(page.Next.IsZero())
+
+	_, err = ldifReader.ReadEntitiesPage(-1, 50)
+	r.Error(err)
+
+	_, err = ldifReader.ReadEntitiesPage(0, 0)
+	r.Error(err)
+}
+
+func TestPages_ReadPageAfter(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+
+	names := []string{}
+	var cursor ldifparser.ResumeToken
+	numPages := 0
+
+	for {
+		page, err := ldifReader.ReadPageAfter(cursor, 2)
+		r.NoError(err)
+		numPages++
+
+		for _, resp := range page.Entities {
+			r.NoError(resp.Error)
+			name, _ := resp.Entity.GetSingleValuedAttribute("sAMAccountName")
+			names = append(names, name)
+		}
+
+		if page.Next.IsZero() {
+			break
+		}
+		cursor = page.Next
+	}
+
+	r.Equal(2, numPages)
+	r.Equal([]string{"MYUSR", "DISABLEDUSER", "MYPC"}, names)
+
+	// a cursor from another input
+	_, err := ldifparser.NewLdifReader(strings.NewReader("dn: CN=USR1,DC=contoso,DC=com\n")).ReadPageAfter(cursor, 2)
+	r.ErrorIs(err, ldifparser.ErrInvalidResumeToken)
+}
+
+func TestPages_DNlessRecord(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewReaderConf()
+	conf.AttributeFilter = entitybuilder.NewAttributeFilter("sAMAccountName")
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(dnlessRecordLdif), conf)
+
+	count, err := ldifReader.CountEntities()
+	r.NoError(err)
+	r.Equal(len(ldifReader.ReadEntities()), count)
+	r.Equal(3, count)
+
+	page, err := ldifReader.ReadEntitiesPage(1, 2)
+	r.NoError(err)
+	r.Len(page.Entities, 2)
+	r.Error(page.Entities[0].Error)
+
+	dn, _ := page.Entities[1].Entity.GetDN()
+	r.Equal("CN=USR2,DC=contoso,DC=com", dn)
+}
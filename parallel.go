@@ -0,0 +1,373 @@
+package ldifparser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// parallelMinChunkSize keeps small inputs from being
+// split into more chunks than is worth parsing in parallel
+const parallelMinChunkSize int64 = 64 * 1024
+
+// chunksPerWorker splits the input into more chunks than
+// workers so that uneven chunks don't leave workers idle
+const chunksPerWorker int = 4
+
+// chunkBufferSize is the number of parsed entities
+// each chunk may hold before its worker blocks
+const chunkBufferSize int = 64
+
+// byteRange is a half-open range of byte offsets
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// inputSize returns the size of the input in bytes
+func (r LdifReader) inputSize() (int64, error) {
+	switch input := r.input.(type) {
+	case interface{ Size() int64 }:
+		return input.Size(), nil
+	case *os.File:
+		info, err := input.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+
+	return readAtSize(r.input)
+}
+
+// readAtSize measures `input` by probing it with ReadAt, so that
+// the input's seek position is neither used nor modified.
+func readAtSize(input io.ReaderAt) (int64, error) {
+	probe := make([]byte, 1)
+	hasByteAt := func(offset int64) (bool, error) {
+		n, err := input.ReadAt(probe, offset)
+		if n == 1 {
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// find an offset past the end, then search
+	// for the first offset without a byte
+	low, high := int64(0), int64(1)
+	for {
+		found, err := hasByteAt(high - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			break
+		}
+		low = high
+		high *= 2
+	}
+
+	for low < high {
+		mid := low + (high-low)/2
+		found, err := hasByteAt(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if found {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
+}
+
+// nextBlockStart returns the offset of the first entity block that starts
+// at or after `offset`, or `end` if there is none. A block starts with the
+// first non-blank line following a blank line.
+func (r LdifReader) nextBlockStart(offset int64, end int64) (int64, error) {
+	if offset == 0 || offset >= end {
+		return offset, nil
+	}
+
+	// start one byte early and skip what remains of that line,
+	// so a block starting exactly at `offset` is still found when
+	// the line that the skipped byte ends is blank
+	scanStart := offset - 1
+	scanner := newPositionedScanner(io.NewSectionReader(r.input, scanStart, end-scanStart), r.ScannerBufferSize)
+	if !scanner.Scan() {
+		return end, scanner.Err()
+	}
+
+	prevBlank, err := r.isBlankBefore(scanStart)
+	if err != nil {
+		return end, err
+	}
+	prevBlank = prevBlank && len(bytes.TrimSpace(scanner.Bytes())) == 0
+
+	for {
+		lineStart := scanStart + scanner.Position()
+		if !scanner.Scan() {
+			return end, scanner.Err()
+		}
+
+		isBlank := len(bytes.TrimSpace(scanner.Bytes())) == 0
+		if prevBlank && !isBlank {
+			return lineStart, nil
+		}
+		prevBlank = isBlank
+	}
+}
+
+// isBlankBefore returns true if the bytes between the start of the
+// line holding `offset` and `offset` are all whitespace. The input is
+// read backwards in small steps, as only a few bytes are usually needed.
+func (r LdifReader) isBlankBefore(offset int64) (bool, error) {
+	buf := make([]byte, 64)
+
+	for offset > 0 {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+
+		_, err := r.input.ReadAt(buf[:n], offset-n)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			switch buf[i] {
+			case '\n':
+				return true, nil
+			case ' ', '\t', '\r', '\v', '\f':
+			default:
+				return false, nil
+			}
+		}
+		offset -= n
+	}
+
+	return true, nil
+}
+
+// splitIntoChunks divides the entity blocks of the input that follow
+// `first` into byte ranges that each begin at the start of an entity block.
+func (r LdifReader) splitIntoChunks(first int64, numChunks int) ([]byteRange, error) {
+	size, err := r.inputSize()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := (size - first) / int64(numChunks)
+	if chunkSize < parallelMinChunkSize {
+		chunkSize = parallelMinChunkSize
+	}
+
+	chunks := []byteRange{}
+	start := first
+
+	for start < size {
+		end, err := r.nextBlockStart(start+chunkSize, size)
+		if end > size {
+			end = size
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, byteRange{start, end})
+		start = end
+	}
+
+	return chunks, nil
+}
+
+// chunkFirstLines returns the number of the first line of each chunk,
+// given the number of the first chunk's first line. Lines are counted
+// before parsing starts, since the chunks are parsed out of order.
+func (r LdifReader) chunkFirstLines(chunks []byteRange, firstLine int) ([]int, error) {
+	firstLines := make([]int, len(chunks))
+	if len(chunks) == 0 {
+		return firstLines, nil
+	}
+
+	lineStart := chunks[0].start
+	line := firstLine
+
+	for i, chunk := range chunks {
+		numLines, err := r.countLines(lineStart, chunk.start)
+		if err != nil {
+			return nil, err
+		}
+
+		line += numLines
+		lineStart = chunk.start
+		firstLines[i] = line
+	}
+
+	return firstLines, nil
+}
+
+// parseChunk parses the entity blocks in `chunk`, whose first line is
+// `firstLine`, passing each result to `send`. Parsing stops once `send`
+// returns false.
+func (r LdifReader) parseChunk(chunk byteRange, firstLine int, send func(EntityResp) bool) {
+	scanner := r.rangeScanner(chunk.start, chunk.end)
+
+	r.scanBlocks(scanner, chunk.start, firstLine, newLineFilter(r.AttributeFilter), func(block entityBlock, err error) bool {
+		return send(r.buildEntityResp(block, err))
+	})
+}
+
+// readEntitiesParallel implements ReadEntitiesChanneled by splitting the
+// input into chunks at entity boundaries and parsing them on a pool of
+// `Parallelism` workers. Chunks are read with io.ReaderAt, so the seek
+// position of the input is not used.
+func (r LdifReader) readEntitiesParallel(interrupt <-chan bool) <-chan EntityResp {
+	results := make(chan EntityResp)
+
+	go func() {
+		defer close(results)
+
+		select {
+		case <-interrupt:
+			return
+		default:
+		}
+
+		first, firstLine, err := r.firstBlockPosition()
+
+		var chunks []byteRange
+		if err == nil {
+			chunks, err = r.splitIntoChunks(first, r.Parallelism*chunksPerWorker)
+		}
+
+		var firstLines []int
+		if err == nil {
+			firstLines, err = r.chunkFirstLines(chunks, firstLine)
+		}
+
+		if err != nil {
+			select {
+			case results <- EntityResp{Error: err, Meta: EntityMeta{Source: r.SourceName}}:
+			case <-interrupt:
+			}
+			return
+		}
+		r.Logger.Info("parsing %d chunks in parallel", len(chunks))
+
+		// stop is closed once the consumer no longer wants results
+		stop := make(chan bool)
+		defer close(stop)
+
+		var chunkResults []chan EntityResp
+		if r.PreserveOrder {
+			chunkResults = r.startOrderedWorkers(chunks, firstLines, stop)
+		} else {
+			chunkResults = r.startUnorderedWorkers(chunks, firstLines, stop)
+		}
+
+		for _, chunkResult := range chunkResults {
+			for resp := range chunkResult {
+				select {
+				case results <- resp:
+				case <-interrupt:
+					return
+				}
+
+				if resp.Error != nil && !r.ContinueOnErr {
+					return
+				}
+			}
+		}
+	}()
+
+	return results
+}
+
+// sendUntilStopped returns a send function for parseChunk that
+// gives up once `stop` has been closed.
+func sendUntilStopped(results chan<- EntityResp, stop <-chan bool) func(EntityResp) bool {
+	return func(resp EntityResp) bool {
+		select {
+		case results <- resp:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// startOrderedWorkers parses each chunk into its own channel. Workers are
+// started in chunk order so that the chunk being consumed always has a
+// worker, even when later chunks are blocked on full channels.
+func (r LdifReader) startOrderedWorkers(chunks []byteRange, firstLines []int, stop <-chan bool) []chan EntityResp {
+	chunkResults := make([]chan EntityResp, len(chunks))
+	for i := range chunkResults {
+		chunkResults[i] = make(chan EntityResp, chunkBufferSize)
+	}
+
+	workerSlots := make(chan bool, r.Parallelism)
+
+	go func() {
+		for i, chunk := range chunks {
+			select {
+			case workerSlots <- true:
+			case <-stop:
+				return
+			}
+
+			go func(chunk byteRange, firstLine int, chunkResult chan EntityResp) {
+				defer func() { <-workerSlots }()
+				defer close(chunkResult)
+
+				r.parseChunk(chunk, firstLine, sendUntilStopped(chunkResult, stop))
+			}(chunk, firstLines[i], chunkResults[i])
+		}
+	}()
+
+	return chunkResults
+}
+
+// startUnorderedWorkers parses every chunk into a single channel,
+// which is closed once all chunks have been parsed.
+func (r LdifReader) startUnorderedWorkers(chunks []byteRange, firstLines []int, stop <-chan bool) []chan EntityResp {
+	merged := make(chan EntityResp, chunkBufferSize)
+	chunkQueue := make(chan int)
+
+	var workers sync.WaitGroup
+	for i := 0; i < r.Parallelism; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range chunkQueue {
+				r.parseChunk(chunks[i], firstLines[i], sendUntilStopped(merged, stop))
+			}
+		}()
+	}
+
+	go func() {
+		defer close(chunkQueue)
+		for i := range chunks {
+			select {
+			case chunkQueue <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(merged)
+	}()
+
+	return []chan EntityResp{merged}
+}
@@ -0,0 +1,146 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func readAllDNs(t *testing.T, reader ldifparser.LdifReader) []string {
+	dns := []string{}
+	for _, resp := range reader.ReadEntities() {
+		require.NoError(t, resp.Error)
+
+		dn, found := resp.Entity.GetDN()
+		require.True(t, found)
+		dns = append(dns, dn)
+	}
+
+	return dns
+}
+
+func TestParallel_PreserveOrder(t *testing.T) {
+	r := require.New(t)
+	ldif := generateLdif(400, 2048)
+
+	sequential := readAllDNs(t, ldifparser.NewLdifReader(bytes.NewReader(ldif)))
+	r.Len(sequential, 400)
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	parallel := readAllDNs(t, ldifparser.NewLdifReader(bytes.NewReader(ldif), conf))
+
+	r.Equal(sequential, parallel)
+}
+
+func TestParallel_Unordered(t *testing.T) {
+	r := require.New(t)
+	ldif := generateLdif(400, 2048)
+
+	sequential := readAllDNs(t, ldifparser.NewLdifReader(bytes.NewReader(ldif)))
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	conf.PreserveOrder = false
+	unordered := readAllDNs(t, ldifparser.NewLdifReader(bytes.NewReader(ldif), conf))
+
+	sort.Strings(sequential)
+	sort.Strings(unordered)
+	r.Equal(sequential, unordered)
+}
+
+func TestParallel_SmallFile(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+
+	reader := openTestReader(t, testFileName)
+	reader.ReaderConf = conf
+
+	r.Len(readAllDNs(t, reader), numTestFileEntities)
+}
+
+func TestParallel_ContinueOnErr(t *testing.T) {
+	r := require.New(t)
+
+	for _, continueOnErr := range []bool{true, false} {
+		conf := ldifparser.NewReaderConf()
+		conf.Parallelism = 2
+		conf.ContinueOnErr = continueOnErr
+
+		reader := openTestReader(t, "test_users_with_err.ldif")
+		reader.ReaderConf = conf
+
+		res := reader.ReadEntities()
+		if continueOnErr {
+			r.Len(res, 3)
+		} else {
+			r.Len(res, 2)
+		}
+
+		r.NoError(res[0].Error)
+		r.Error(res[1].Error)
+	}
+}
+
+func TestParallel_Interrupt(t *testing.T) {
+	r := require.New(t)
+	ldif := generateLdif(400, 2048)
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	reader := ldifparser.NewLdifReader(bytes.NewReader(ldif), conf)
+
+	interrupt := make(chan bool)
+	results := reader.ReadEntitiesChanneled(interrupt)
+
+	resp := <-results
+	r.NoError(resp.Error)
+	close(interrupt)
+
+	for range results {
+	}
+}
+
+func BenchmarkReader_Parallel(b *testing.B) {
+	ldif := generateLdif(2000, 4096)
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	benchmarkReadEntities(b, ldif, conf)
+}
+
+func BenchmarkReader_Sequential(b *testing.B) {
+	ldif := generateLdif(2000, 4096)
+	benchmarkReadEntities(b, ldif, ldifparser.NewReaderConf())
+}
+
+func TestParallel_EntityMeta(t *testing.T) {
+	r := require.New(t)
+	ldif := generateLdif(400, 2048)
+
+	sequential := ldifparser.NewLdifReader(bytes.NewReader(ldif)).ReadEntities()
+
+	for _, preserveOrder := range []bool{true, false} {
+		conf := ldifparser.NewReaderConf()
+		conf.Parallelism = 4
+		conf.PreserveOrder = preserveOrder
+		parallel := ldifparser.NewLdifReader(bytes.NewReader(ldif), conf).ReadEntities()
+		r.Len(parallel, len(sequential))
+
+		sort.Slice(parallel, func(i, j int) bool {
+			return parallel[i].Meta.Offset < parallel[j].Meta.Offset
+		})
+
+		for i := range sequential {
+			r.Equal(sequential[i].Meta, parallel[i].Meta)
+		}
+	}
+
+	last := sequential[len(sequential)-1].Meta
+	r.Equal(bytes.Count(ldif[:last.Offset], []byte("\n"))+1, last.Line)
+}
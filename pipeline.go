@@ -0,0 +1,296 @@
+package ldifparser
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser/syntax"
+)
+
+// EmitFunc passes an entity on to the next stage of a Pipeline.
+type EmitFunc func(e entity.Entity) error
+
+// Stage is a single step of a Pipeline. A stage may call `emit`
+// any number of times to pass entities on to the next stage.
+type Stage interface {
+	Process(e entity.Entity, emit EmitFunc) error
+}
+
+// StageFunc adapts an ordinary function into a Stage.
+type StageFunc func(e entity.Entity, emit EmitFunc) error
+
+func (f StageFunc) Process(e entity.Entity, emit EmitFunc) error {
+	return f(e, emit)
+}
+
+// FilterStage passes on only the entities for which `keep` returns true.
+func FilterStage(keep func(e entity.Entity) bool) Stage {
+	return StageFunc(func(e entity.Entity, emit EmitFunc) error {
+		if !keep(e) {
+			return nil
+		}
+
+		return emit(e)
+	})
+}
+
+// MapStage replaces each entity with the result of `mapper`.
+func MapStage(mapper func(e entity.Entity) (entity.Entity, error)) Stage {
+	return StageFunc(func(e entity.Entity, emit EmitFunc) error {
+		mapped, err := mapper(e)
+		if err != nil {
+			return err
+		}
+
+		return emit(mapped)
+	})
+}
+
+// FlatMapStage replaces each entity with zero or more entities.
+func FlatMapStage(mapper func(e entity.Entity) ([]entity.Entity, error)) Stage {
+	return StageFunc(func(e entity.Entity, emit EmitFunc) error {
+		mapped, err := mapper(e)
+		if err != nil {
+			return err
+		}
+
+		for _, mappedEntity := range mapped {
+			if err := emit(mappedEntity); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// HasObjectClass returns a FilterStage predicate that matches
+// entities with any of the given object classes.
+func HasObjectClass(classes ...string) func(e entity.Entity) bool {
+	return func(e entity.Entity) bool {
+		objClasses, found := e.GetAttribute("objectClass")
+		if !found {
+			return false
+		}
+
+		for _, objClass := range objClasses.GetValues() {
+			for _, class := range classes {
+				if strings.EqualFold(objClass, class) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// rebuildEntity copies `e` into a new entity, passing every attribute
+// other than the DN through `modify`. Attributes for which `modify`
+// returns false are left out of the copy.
+func rebuildEntity(e entity.Entity, modify func(attr entity.Attribute) (entity.Attribute, bool)) (entity.Entity, error) {
+	dn, found := e.GetDN()
+	if !found {
+		return entity.Entity{}, errors.New("unable to find DN in entity")
+	}
+
+	rebuilt := entity.NewEntity(dn)
+	for _, name := range e.GetAllAttributeNames() {
+		if name == "dn" {
+			continue
+		}
+
+		attr, _ := e.GetAttribute(name)
+		attr, keep := modify(attr)
+		if keep {
+			rebuilt.AddAttribute(attr)
+		}
+	}
+
+	return rebuilt, nil
+}
+
+// DropAttributesStage removes the named attributes from every entity.
+// The DN can't be dropped.
+func DropAttributesStage(names ...string) Stage {
+	return MapStage(func(e entity.Entity) (entity.Entity, error) {
+		return rebuildEntity(e, func(attr entity.Attribute) (entity.Attribute, bool) {
+			for _, name := range names {
+				if strings.EqualFold(attr.Name, name) {
+					return attr, false
+				}
+			}
+
+			return attr, true
+		})
+	})
+}
+
+// RenameAttributeStage renames the attribute `from` to `to`. Values
+// are merged if the entity already holds an attribute named `to`.
+func RenameAttributeStage(from string, to string) Stage {
+	return MapStage(func(e entity.Entity) (entity.Entity, error) {
+		return rebuildEntity(e, func(attr entity.Attribute) (entity.Attribute, bool) {
+			if !strings.EqualFold(attr.Name, from) {
+				return attr, true
+			}
+
+			return entity.NewEntityAttribute(to, attr.GetValues()...), true
+		})
+	})
+}
+
+// RewriteValuesStage replaces every value of the named attribute with
+// the result of `rewrite`, which makes it useful for redacting values.
+func RewriteValuesStage(name string, rewrite func(val string) (string, error)) Stage {
+	return MapStage(func(e entity.Entity) (entity.Entity, error) {
+		var rewriteErr error
+
+		rewritten, err := rebuildEntity(e, func(attr entity.Attribute) (entity.Attribute, bool) {
+			if !strings.EqualFold(attr.Name, name) {
+				return attr, true
+			}
+
+			newVals := make([]string, 0, attr.Value.Size())
+			for _, val := range attr.GetValues() {
+				newVal, err := rewrite(val)
+				if err != nil && rewriteErr == nil {
+					rewriteErr = err
+				}
+				newVals = append(newVals, newVal)
+			}
+
+			return entity.NewEntityAttribute(attr.Name, newVals...), true
+		})
+
+		if err == nil {
+			err = rewriteErr
+		}
+		return rewritten, err
+	})
+}
+
+// RewriteDNStage replaces the DN of every entity with the result of `rewrite`.
+// The distinguishedName attribute is updated as well if it is present.
+func RewriteDNStage(rewrite func(dn syntax.DN) (syntax.DN, error)) Stage {
+	return MapStage(func(e entity.Entity) (entity.Entity, error) {
+		dnStr, found := e.GetDN()
+		if !found {
+			return entity.Entity{}, errors.New("unable to find DN in entity")
+		}
+
+		dn, err := syntax.ParseDN(dnStr)
+		if err != nil {
+			return entity.Entity{}, err
+		}
+
+		newDN, err := rewrite(dn)
+		if err != nil {
+			return entity.Entity{}, err
+		}
+
+		rewritten, err := rebuildEntity(e, func(attr entity.Attribute) (entity.Attribute, bool) {
+			return attr, true
+		})
+		if err != nil {
+			return entity.Entity{}, err
+		}
+
+		rewritten.SetAttribute(entity.NewEntityAttribute("dn", newDN.String()))
+		if dnAttr, found := rewritten.GetAttribute("distinguishedName"); found {
+			rewritten.SetAttribute(entity.NewEntityAttribute(dnAttr.Name, newDN.String()))
+		}
+
+		return rewritten, nil
+	})
+}
+
+// Pipeline runs entities through a series of stages. Entities are
+// processed one at a time as they are read, so memory use does not
+// grow with the size of the input.
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline constructs a Pipeline that runs the stages in order.
+func NewPipeline(stages ...Stage) Pipeline {
+	return Pipeline{stages: stages}
+}
+
+var errPipelineInterrupted = errors.New("pipeline interrupted")
+
+// process runs `e` through the stages starting at `stageIdx`
+func (p Pipeline) process(e entity.Entity, stageIdx int, emit EmitFunc) error {
+	if stageIdx == len(p.stages) {
+		return emit(e)
+	}
+
+	return p.stages[stageIdx].Process(e, func(out entity.Entity) error {
+		return p.process(out, stageIdx+1, emit)
+	})
+}
+
+// Apply runs every entity received from `entities` through the pipeline and
+// returns the results via a channel. Errors on the input are passed through
+// unchanged. Errors returned by a stage are sent in place of the entity and
+// include the entity's DN. Results keep the Meta and Resume token of the
+// entity they were produced from, so a token resumes after every result of
+// that entity. Closing `interrupt` stops processing and closes the channel.
+func (p Pipeline) Apply(entities <-chan EntityResp, interrupt <-chan bool) <-chan EntityResp {
+	results := make(chan EntityResp)
+
+	send := func(resp EntityResp) error {
+		select {
+		case results <- resp:
+			return nil
+		case <-interrupt:
+			return errPipelineInterrupted
+		}
+	}
+
+	go func() {
+		defer close(results)
+
+		for resp := range entities {
+			if resp.Error != nil {
+				if send(resp) != nil {
+					return
+				}
+				continue
+			}
+
+			err := p.process(resp.Entity, 0, func(out entity.Entity) error {
+				return send(EntityResp{Entity: out, Meta: resp.Meta, Resume: resp.Resume})
+			})
+
+			if err == errPipelineInterrupted {
+				return
+			}
+
+			if err != nil {
+				dn, _ := resp.Entity.GetDN()
+				err = merry.Prependf(err, "pipeline failed for entity %s", dn)
+				if send(EntityResp{Error: err, Meta: resp.Meta, Resume: resp.Resume}) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return results
+}
+
+// Run streams every entity read by `r` through the pipeline and into `w`.
+// Failed entities are handled by `policy`, and the returned summary counts
+// the entities produced by the pipeline. The writer is not flushed.
+func (p Pipeline) Run(r LdifReader, w *LdifWriter, policy ErrorPolicy) (CopySummary, error) {
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	entities := r.ReadEntitiesChanneled(interrupt)
+	results := p.Apply(entities, interrupt)
+
+	return w.WriteEntities(results, CopyOptions{ErrorPolicy: policy})
+}
@@ -0,0 +1,130 @@
+package ldifparser_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser"
+	"github.com/kgoins/ldifparser/syntax"
+	"github.com/stretchr/testify/require"
+)
+
+func runTestPipeline(t *testing.T, p ldifparser.Pipeline) []ldifparser.EntityResp {
+	interrupt := make(chan bool)
+	defer close(interrupt)
+
+	entities := openTestReader(t, testFileName).ReadEntitiesChanneled(interrupt)
+
+	results := []ldifparser.EntityResp{}
+	for resp := range p.Apply(entities, interrupt) {
+		results = append(results, resp)
+	}
+
+	return results
+}
+
+func TestPipeline_Stages(t *testing.T) {
+	r := require.New(t)
+
+	redact := func(val string) (string, error) {
+		return "REDACTED", nil
+	}
+
+	moveToArchive := func(dn syntax.DN) (syntax.DN, error) {
+		archive := syntax.MustParseDN("OU=Archive,DC=contoso,DC=com")
+		archive.RDNs = append([]syntax.RDN{dn.RDN()}, archive.RDNs...)
+		return archive, nil
+	}
+
+	p := ldifparser.NewPipeline(
+		ldifparser.FilterStage(ldifparser.HasObjectClass("PERSON")),
+		ldifparser.DropAttributesStage("objectGUID", "objectSid", "dn"),
+		ldifparser.RenameAttributeStage("sAMAccountName", "uid"),
+		ldifparser.RewriteValuesStage("userPrincipalName", redact),
+		ldifparser.RewriteDNStage(moveToArchive),
+	)
+
+	results := runTestPipeline(t, p)
+	r.Len(results, 2)
+
+	for _, resp := range results {
+		r.NoError(resp.Error)
+		e := resp.Entity
+
+		dn, _ := e.GetDN()
+		r.True(strings.HasSuffix(dn, ",OU=Archive,DC=contoso,DC=com"), dn)
+
+		distinguishedName, _ := e.GetSingleValuedAttribute("distinguishedName")
+		r.Equal(dn, distinguishedName)
+
+		_, found := e.GetAttribute("objectGUID")
+		r.False(found)
+		_, found = e.GetAttribute("sAMAccountName")
+		r.False(found)
+
+		uid, found := e.GetSingleValuedAttribute("uid")
+		r.True(found)
+		r.Contains(dn, "CN="+uid+",")
+
+		upn, _ := e.GetSingleValuedAttribute("userPrincipalName")
+		r.Equal("REDACTED", upn)
+	}
+}
+
+func TestPipeline_FlatMap(t *testing.T) {
+	r := require.New(t)
+
+	p := ldifparser.NewPipeline(
+		ldifparser.FlatMapStage(func(e entity.Entity) ([]entity.Entity, error) {
+			return []entity.Entity{e, e}, nil
+		}),
+	)
+
+	results := runTestPipeline(t, p)
+	r.Len(results, 2*numTestFileEntities)
+
+	// both copies resume after the entity they came from
+	entities := openTestReader(t, testFileName).ReadEntities()
+	for i, resp := range results {
+		r.False(resp.Resume.IsZero())
+		r.Equal(entities[i/2].Resume, resp.Resume)
+	}
+}
+
+func TestPipeline_ErrorsIncludeDN(t *testing.T) {
+	r := require.New(t)
+
+	p := ldifparser.NewPipeline(
+		ldifparser.MapStage(func(e entity.Entity) (entity.Entity, error) {
+			return e, errors.New("stage failed")
+		}),
+	)
+
+	results := runTestPipeline(t, p)
+	r.Len(results, numTestFileEntities)
+
+	r.Error(results[0].Error)
+	r.False(results[0].Resume.IsZero())
+	r.Contains(results[0].Error.Error(), "stage failed")
+	r.Contains(results[0].Error.Error(), "CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com")
+}
+
+func TestPipeline_Run(t *testing.T) {
+	r := require.New(t)
+
+	p := ldifparser.NewPipeline(
+		ldifparser.FilterStage(ldifparser.HasObjectClass("computer")),
+	)
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	summary, err := p.Run(openTestReader(t, testFileName), writer, ldifparser.AbortOnError)
+	r.NoError(err)
+	r.NoError(writer.Flush())
+
+	r.Equal(ldifparser.CopySummary{Read: 1, Written: 1}, summary)
+	r.Contains(outBuffer.String(), "# MYPC, ContosoUsers, contoso.com\n")
+}
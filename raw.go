@@ -0,0 +1,150 @@
+package ldifparser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// RawBlock is the unparsed text of a single record, exactly as it appears
+// in the input, including its title comment, folded lines and line endings.
+type RawBlock struct {
+	Data []byte
+	Meta EntityMeta
+}
+
+type RawBlockResp struct {
+	Block  RawBlock
+	Error  error
+	Resume ResumeToken
+}
+
+// RawPredicate decides whether a RawBlock is kept. Predicates see
+// only the raw text of a record, so they are much cheaper than
+// parsing the entity, but can't see decoded or unfolded values.
+type RawPredicate func(block RawBlock) bool
+
+// RawContains returns a RawPredicate that matches blocks containing
+// `substr`. Text that has been folded onto a continuation line is
+// not matched across the fold.
+func RawContains(substr string) RawPredicate {
+	target := []byte(substr)
+	return func(block RawBlock) bool {
+		return bytes.Contains(block.Data, target)
+	}
+}
+
+// RawAttributeMatches returns a RawPredicate that matches blocks holding
+// a value of the attribute `name` for which `match` returns true. Values
+// are unfolded but not decoded, so base64 values are passed in their
+// encoded form.
+func RawAttributeMatches(name string, match func(value string) bool) RawPredicate {
+	prefix := []byte(name + ":")
+
+	return func(block RawBlock) bool {
+		data := block.Data
+		for len(data) > 0 {
+			var line []byte
+			line, data = nextRawLine(data)
+
+			if len(line) < len(prefix) || !bytes.EqualFold(line[:len(prefix)], prefix) {
+				continue
+			}
+			value := line[len(prefix):]
+
+			// unfold the value, copying it so the input isn't modified
+			for len(data) > 0 && data[0] == ' ' {
+				var continuation []byte
+				continuation, data = nextRawLine(data)
+				value = append(value[:len(value):len(value)], continuation[1:]...)
+			}
+
+			// skip the `:` or `<` of base64 and URL values
+			if len(value) > 0 && (value[0] == ':' || value[0] == '<') {
+				value = value[1:]
+			}
+
+			if match(strings.TrimLeft(string(value), " ")) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// nextRawLine splits the first line, without its line ending, from `data`.
+func nextRawLine(data []byte) (line []byte, rest []byte) {
+	lineEnd := bytes.IndexByte(data, '\n')
+	if lineEnd < 0 {
+		return bytes.TrimSuffix(data, []byte{'\r'}), nil
+	}
+
+	return bytes.TrimSuffix(data[:lineEnd], []byte{'\r'}), data[lineEnd+1:]
+}
+
+// rawBytes returns the input between `start` and `end`. In-memory inputs
+// are only copied when ZeroCopy is not set.
+func (r LdifReader) rawBytes(start int64, end int64) ([]byte, error) {
+	if data, inMemory := r.inputBytes(); inMemory {
+		if r.ZeroCopy {
+			return data[start:end:end], nil
+		}
+		return append([]byte(nil), data[start:end]...), nil
+	}
+
+	raw := make([]byte, end-start)
+	_, err := io.ReadFull(io.NewSectionReader(r.input, start, end-start), raw)
+	return raw, err
+}
+
+// ReadRawBlocksChanneled returns the raw text of each entity record in the
+// input that `keep` matches, or of every entity record if `keep` is nil.
+// Entities are never parsed, so malformed records are passed through as
+// they are. Errors and `interrupt` are handled the same way as in
+// ReadEntitiesChanneled.
+func (r LdifReader) ReadRawBlocksChanneled(keep RawPredicate, interrupt <-chan bool) <-chan RawBlockResp {
+	results := make(chan RawBlockResp)
+
+	go func() {
+		defer close(results)
+
+		select {
+		case <-interrupt:
+			return
+		default:
+		}
+
+		scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+		if err != nil {
+			select {
+			case results <- RawBlockResp{Error: err}:
+			case <-interrupt:
+			}
+			return
+		}
+
+		r.scanBlocks(scanner, blockPos, firstLine, boundaryFilter(), func(block entityBlock, err error) bool {
+			var raw RawBlock
+			resume := blockResumeToken(block)
+			if err == nil {
+				raw.Meta = r.blockMeta(block)
+				raw.Data, err = r.rawBytes(block.start, block.end)
+			}
+
+			if err == nil && keep != nil && !keep(raw) {
+				return true
+			}
+
+			select {
+			case results <- RawBlockResp{raw, err, resume}:
+			case <-interrupt:
+				return false
+			}
+
+			return err == nil || r.ContinueOnErr
+		})
+	}()
+
+	return results
+}
@@ -0,0 +1,119 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaw_CopyRawBlocks(t *testing.T) {
+	r := require.New(t)
+
+	input, err := os.ReadFile(filepath.Join(getTestDataDir(), testFileName))
+	r.NoError(err)
+
+	var out bytes.Buffer
+	w := ldifparser.NewLdifWriter(&out)
+
+	isDisabled := ldifparser.RawAttributeMatches("samaccountname", func(value string) bool {
+		return strings.EqualFold(value, "disableduser")
+	})
+
+	summary, err := ldifparser.CopyRawBlocks(ldifparser.NewLdifReader(bytes.NewReader(input)), w, isDisabled, ldifparser.AbortOnError)
+	r.NoError(err)
+	r.NoError(w.Flush())
+	r.Equal(ldifparser.CopySummary{Read: 3, Written: 1, Dropped: 2}, summary)
+
+	// the record is copied byte for byte
+	start := bytes.Index(input, []byte("# DISABLEDUSER"))
+	end := bytes.Index(input, []byte("# MYPC"))
+	r.Equal(string(input[start:end]), out.String())
+
+	// copying every record keeps all of the entities
+	out.Reset()
+	w = ldifparser.NewLdifWriter(&out)
+
+	summary, err = ldifparser.CopyRawBlocks(ldifparser.NewLdifReader(bytes.NewReader(input)), w, nil, ldifparser.AbortOnError)
+	r.NoError(err)
+	r.NoError(w.Flush())
+	r.Equal(3, summary.Written)
+
+	entities := ldifparser.NewLdifReader(bytes.NewReader(out.Bytes())).ReadEntities()
+	r.Len(entities, numTestFileEntities)
+}
+
+func TestRaw_ReadRawBlocksChanneled(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"version: 1",
+		"",
+		"# USR1, contoso.com",
+		"dn: CN=USR1,DC=contoso,DC=com",
+		"description: first line that is",
+		"  folded",
+		"cn:: VVNSMQ==",
+		"",
+		"dn: CN=USR2,DC=contoso,DC=com",
+		"description: second",
+		"",
+		"# search result",
+		"search: 2",
+		"result: 0 Success",
+		"",
+	}, "\r\n")
+
+	readBlocks := func(keep ldifparser.RawPredicate) []ldifparser.RawBlock {
+		interrupt := make(chan bool)
+		defer close(interrupt)
+
+		blocks := []ldifparser.RawBlock{}
+		reader := ldifparser.NewLdifReader(strings.NewReader(input))
+		for resp := range reader.ReadRawBlocksChanneled(keep, interrupt) {
+			r.NoError(resp.Error)
+			blocks = append(blocks, resp.Block)
+		}
+
+		return blocks
+	}
+
+	blocks := readBlocks(nil)
+	r.Len(blocks, 2)
+	r.Equal("dn: CN=USR2,DC=contoso,DC=com\r\ndescription: second\r\n", string(blocks[1].Data))
+	r.Equal("USR1, contoso.com", blocks[0].Meta.Title)
+	r.Equal(9, blocks[1].Meta.Line)
+
+	isFolded := ldifparser.RawAttributeMatches("description", func(value string) bool {
+		return value == "first line that is folded"
+	})
+	blocks = readBlocks(isFolded)
+	r.Len(blocks, 1)
+	r.True(bytes.HasPrefix(blocks[0].Data, []byte("# USR1")))
+
+	isEncoded := ldifparser.RawAttributeMatches("cn", func(value string) bool {
+		return value == "VVNSMQ=="
+	})
+	r.Len(readBlocks(isEncoded), 1)
+
+	r.Len(readBlocks(ldifparser.RawContains("second")), 1)
+	r.Empty(readBlocks(ldifparser.RawContains("Success")))
+}
+
+func TestRaw_PassesThroughDNlessRecord(t *testing.T) {
+	r := require.New(t)
+
+	var out bytes.Buffer
+	w := ldifparser.NewLdifWriter(&out)
+
+	summary, err := ldifparser.CopyRawBlocks(ldifparser.NewLdifReader(strings.NewReader(dnlessRecordLdif)), w, nil, ldifparser.AbortOnError)
+	r.NoError(err)
+	r.NoError(w.Flush())
+
+	r.Equal(3, summary.Written)
+	r.Equal(dnlessRecordLdif+"\n", out.String())
+}
@@ -2,19 +2,22 @@ package ldifparser
 
 import (
 	"bufio"
+	"bytes"
 	"io"
+	"math"
 	"strings"
 
 	"github.com/ansel1/merry/v2"
-	"github.com/kgoins/backscanner"
 
 	"github.com/kgoins/ldapentity/entity"
-	"github.com/kgoins/poscanner"
 
 	"github.com/kgoins/ldifparser/entitybuilder"
 	"github.com/kgoins/ldifparser/syntax"
 )
 
+// errNoEntityBlock is returned when the input holds no entities
+var errNoEntityBlock = merry.New("unable to locate first entity block")
+
 type ReadSeekerAt interface {
 	io.ReadSeeker
 	io.ReaderAt
@@ -27,9 +30,14 @@ type Scanner interface {
 	Position() int64
 }
 
-// LdifReader constructs LDAP Entities from an ldif file.
+// LdifReader constructs LDAP Entities from an ldif file. The input
+// is only read with io.ReaderAt, so an LdifReader never depends on or
+// modifies the input's seek position and may be used by many goroutines
+// at once, provided the input's ReadAt is safe for concurrent use as it
+// is for files, memory mapped files and in-memory readers.
 type LdifReader struct {
 	input ReadSeekerAt
+	index *entityIndex
 	ReaderConf
 }
 
@@ -46,6 +54,7 @@ func NewLdifReader(input ReadSeekerAt, conf ...ReaderConf) LdifReader {
 
 	return LdifReader{
 		input:      input,
+		index:      &entityIndex{},
 		ReaderConf: actualConf,
 	}
 }
@@ -56,139 +65,455 @@ func (r *LdifReader) SetAttributeFilter(filter entitybuilder.AttributeFilter) {
 	r.AttributeFilter = filter
 }
 
-// getEntityFromBlock constructs an entity from the lines starting
-// at the scanner's current position. At the end of this call, the
-// scanner will be positioned at the end of the entity.
-func (r LdifReader) getEntityFromBlock(entityBlock Scanner) (entity.Entity, error) {
-	entityLines := []string{}
+// blockBuffer holds the lines of an entity block while it is read.
+// Its buffers are reused between blocks, and each line kept from a block
+// is copied into its own string, so that values retained from an entity
+// don't keep the rest of its record alive. When a source is set, lines
+// that were not unfolded refer to the source instead of being copied.
+type blockBuffer struct {
+	data  []byte
+	spans []lineSpan
+	lines []string
+
+	source string
+
+	// linesScanned counts every line read into the buffer, including
+	// blank and filtered lines, so that blocks know their line numbers
+	linesScanned int
+}
+
+// lineSpan locates a line in the source when srcOffset is not
+// negative, or in the data buffer otherwise. Lines in the source
+// run from srcOffset to srcOffset + end.
+type lineSpan struct {
+	srcOffset int64
+	start     int
+	end       int
+}
+
+// newBlockBuffer returns a blockBuffer that refers to the input
+// instead of copying it when zero-copy reads are possible.
+func (r LdifReader) newBlockBuffer() *blockBuffer {
+	buf := &blockBuffer{}
+
+	data, inMemory := r.inputBytes()
+	if inMemory && r.ZeroCopy {
+		buf.source = unsafeString(data)
+	}
+
+	return buf
+}
+
+func (bb *blockBuffer) reset() {
+	bb.data = bb.data[:0]
+	bb.spans = bb.spans[:0]
+}
+
+func (bb *blockBuffer) numLines() int {
+	return len(bb.spans)
+}
+
+// addLine adds `line`, which starts at `srcOffset` of the
+// source, or is copied if `srcOffset` is negative.
+func (bb *blockBuffer) addLine(line []byte, srcOffset int64) {
+	if bb.source != "" && srcOffset >= 0 {
+		bb.spans = append(bb.spans, lineSpan{srcOffset: srcOffset, end: len(line)})
+		return
+	}
+
+	start := len(bb.data)
+	bb.data = append(bb.data, line...)
+	bb.spans = append(bb.spans, lineSpan{srcOffset: -1, start: start, end: len(bb.data)})
+}
+
+// appendToLine extends the most recently added line.
+func (bb *blockBuffer) appendToLine(continuation []byte) {
+	last := &bb.spans[len(bb.spans)-1]
+
+	// an unfolded line no longer matches the source
+	if last.srcOffset >= 0 {
+		line := bb.source[last.srcOffset : last.srcOffset+int64(last.end)]
+		last.start = len(bb.data)
+		last.srcOffset = -1
+		bb.data = append(bb.data, line...)
+	}
+
+	bb.data = append(bb.data, continuation...)
+	last.end = len(bb.data)
+}
+
+// getLines returns the lines of the block. The returned slice
+// is only valid until the buffer is reset.
+func (bb *blockBuffer) getLines() []string {
+	bb.lines = bb.lines[:0]
+
+	for _, span := range bb.spans {
+		if span.srcOffset >= 0 {
+			bb.lines = append(bb.lines, bb.source[span.srcOffset:span.srcOffset+int64(span.end)])
+		} else {
+			bb.lines = append(bb.lines, string(bb.data[span.start:span.end]))
+		}
+	}
+
+	return bb.lines
+}
+
+// entityBlock is a record read from the input. The offsets locate the
+// start of the record's first line and the end of its last line, line is
+// the number of the record's first line and nextLine is the number of the
+// line that follows the record. Records ended by a blank line, rather than
+// the end of the input, are terminated. A block is found once a record has
+// been read into it, and isEntity is false for records that hold no entity,
+// such as the comment-only and search result blocks written by ldapsearch.
+// Both are decided before any lines are filtered.
+type entityBlock struct {
+	lines      []string
+	start      int64
+	end        int64
+	line       int
+	nextLine   int
+	terminated bool
+	found      bool
+	isEntity   bool
+}
+
+// readEntityBlock returns the record starting at the scanner's current
+// position, skipping any blank lines before it and unfolding continuation
+// lines. Attribute lines rejected by the optional lineFilter are dropped
+// without being copied out of the scan buffer. At the end of this call,
+// the scanner will be positioned at the end of the record. A block that was
+// not found is returned once the input is exhausted. Offsets are relative to
+// the scanner, the line number counts from zero at the scanner's first
+// line, and the lines are reused by the next read into `buf`.
+func (r LdifReader) readEntityBlock(scanner Scanner, filter *lineFilter, buf *blockBuffer) (entityBlock, error) {
+	buf.reset()
+	block := entityBlock{}
+	hasStarted := false
+	hasAttrLine := false
+	skipContinuation := false
+
+	// scanners over in-memory inputs report where each line starts
+	offsetScanner, hasOffsets := scanner.(interface{ lineOffset() int64 })
+	// the scanners of this package return lines without copying them
+	lineScanner, hasLineBytes := scanner.(interface{ Bytes() []byte })
 
-	for entityBlock.Scan() {
-		line := entityBlock.Text()
-		if syntax.IsEntitySeparator(line) {
+	for {
+		lineStart := scanner.Position()
+		if !scanner.Scan() {
 			break
 		}
+		buf.linesScanned++
+
+		var line []byte
+		if hasLineBytes {
+			line = lineScanner.Bytes()
+		} else {
+			line = []byte(scanner.Text())
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if !hasStarted {
+				continue
+			}
+			block.terminated = true
+			break
+		}
+
+		if !hasStarted {
+			block.start = lineStart
+			block.line = buf.linesScanned - 1
+			hasStarted = true
+		}
+		block.end = scanner.Position()
+		block.nextLine = buf.linesScanned
+
+		// unfold continuation lines onto the line they continue
+		if line[0] == ' ' && (buf.numLines() > 0 || skipContinuation) {
+			if !skipContinuation {
+				buf.appendToLine(line[1:])
+			}
+			continue
+		}
+
+		if !hasAttrLine && line[0] != '#' {
+			hasAttrLine = true
+			block.isEntity = !syntax.IsSearchResultLine(string(line))
+		}
 
-		entityLines = append(entityLines, line)
+		skipContinuation = line[0] != '#' && filter.isFiltered(line)
+		if skipContinuation {
+			continue
+		}
+
+		srcOffset := int64(-1)
+		if hasOffsets {
+			srcOffset = offsetScanner.lineOffset()
+		}
+		buf.addLine(line, srcOffset)
 	}
 
-	if entityBlock.Err() != nil {
-		err := merry.Wrap(entityBlock.Err(), merry.AppendMessagef(
-			"error at position [%d]", entityBlock.Position(),
+	if scanner.Err() != nil {
+		err := merry.Wrap(scanner.Err(), merry.AppendMessagef(
+			"error at position [%d]", scanner.Position(),
 		))
-		return entity.Entity{}, err
+		return entityBlock{}, err
+	}
+
+	block.found = hasStarted
+	block.lines = buf.getLines()
+	return block, nil
+}
+
+// rereadBlock reads `block` from the input again, keeping its position,
+// so that the lines dropped by a narrower filter are restored.
+func (r LdifReader) rereadBlock(block entityBlock) (entityBlock, error) {
+	reread, err := r.readEntityBlock(r.rangeScanner(block.start, block.end), newLineFilter(r.AttributeFilter), r.newBlockBuffer())
+	reread.start = block.start
+	reread.end = block.end
+	reread.line = block.line
+	reread.nextLine = block.nextLine
+
+	if err == nil && !reread.found {
+		err = merry.Errorf("unable to read the entity at position [%d] again", block.start)
 	}
 
-	return entitybuilder.BuildEntity(entityLines, r.AttributeFilter)
+	return reread, err
 }
 
 func (r LdifReader) newScanner(readSrc io.Reader) Scanner {
-	return poscanner.NewPositionedScanner(readSrc, r.ScannerBufferSize)
+	return newPositionedScanner(readSrc, r.ScannerBufferSize)
+}
+
+// inputBytes returns the contents of the input if it is held in memory.
+func (r LdifReader) inputBytes() ([]byte, bool) {
+	src, inMemory := r.input.(byteSource)
+	if !inMemory {
+		return nil, false
+	}
+
+	return src.Bytes(), true
+}
+
+// newBytesScanner returns a scanner over the in-memory
+// input `data`, starting at `offset`.
+func (r LdifReader) newBytesScanner(data []byte, offset int64) Scanner {
+	return newBytesScanner(data[offset:], offset, r.ScannerBufferSize)
+}
+
+// sectionFrom returns a reader over the input starting at `offset`
+// that does not depend on, or modify, the input's seek position.
+func (r LdifReader) sectionFrom(offset int64) *io.SectionReader {
+	return io.NewSectionReader(r.input, offset, math.MaxInt64-offset)
+}
+
+// scannerAt returns a scanner over the input starting at `offset`.
+// Positions reported by the scanner are relative to `offset`.
+func (r LdifReader) scannerAt(offset int64) Scanner {
+	if data, inMemory := r.inputBytes(); inMemory {
+		return r.newBytesScanner(data, offset)
+	}
+
+	return r.newScanner(r.sectionFrom(offset))
 }
 
-func (r *LdifReader) getScannerAtFirstEntityBlock() (Scanner, error) {
-	scanner := r.newScanner(r.input)
+// rangeScanner returns a scanner over the input between `start` and `end`.
+// Positions reported by the scanner are relative to `start`.
+func (r LdifReader) rangeScanner(start int64, end int64) Scanner {
+	if data, inMemory := r.inputBytes(); inMemory {
+		return r.newBytesScanner(data[:end], start)
+	}
+
+	return r.newScanner(io.NewSectionReader(r.input, start, end-start))
+}
+
+// countLines returns the number of lines that
+// end between offsets `start` and `end` of the input.
+func (r LdifReader) countLines(start int64, end int64) (int, error) {
+	newline := []byte{'\n'}
+	if data, inMemory := r.inputBytes(); inMemory {
+		return bytes.Count(data[start:end], newline), nil
+	}
+
+	section := io.NewSectionReader(r.input, start, end-start)
+	buf := make([]byte, initialScanBufferSize)
+	numLines := 0
 
-	pos := scanner.Position()
+	for {
+		n, err := section.Read(buf)
+		numLines += bytes.Count(buf[:n], newline)
+
+		if err == io.EOF {
+			return numLines, nil
+		}
+		if err != nil {
+			return numLines, err
+		}
+	}
+}
+
+// findFirstEntityBlock returns the offset, relative to the start of
+// `input`, of the first entity block after any version line and prologue.
+func (r LdifReader) findFirstEntityBlock(input io.Reader) (int64, error) {
+	scanner := r.newScanner(input)
+
+	// the block starts after the last separator so
+	// that the title comment of the first entity is kept
+	blockPos := scanner.Position()
 	for scanner.Scan() {
 		line := scanner.Text()
+		if syntax.IsEntitySeparator(line) || syntax.IsVersionLine(line) {
+			blockPos = scanner.Position()
+			continue
+		}
+
 		if !syntax.IsLdifAttributeLine(line) {
-			pos = scanner.Position()
 			continue
 		}
 
-		r.input.Seek(pos, 0)
-		return r.newScanner(r.input), nil
+		return blockPos, nil
 	}
 
 	if scanner.Err() != nil {
 		err := merry.Wrap(scanner.Err(), merry.AppendMessagef(
 			"error at position [%d]", scanner.Position(),
 		))
-		return nil, err
+		return -1, err
 	}
 
-	return nil, merry.New("unable to locate first entity block")
+	return -1, merry.Wrap(errNoEntityBlock)
 }
 
-// getKeyAddrOffset returns -1 if the entity is not found
-func (r LdifReader) getKeyAttrOffset(keyAttr entity.Attribute) (int64, error) {
-	keyAttrStr := strings.ToLower(StringifyAttribute(keyAttr)[0])
-	r.Logger.Info("searching with key: \"%s\"", keyAttrStr)
+// firstBlockPosition returns the offset and line number where reading
+// starts, which is the position of the ResumeFrom token when one is set
+// and the first entity block otherwise.
+func (r LdifReader) firstBlockPosition() (int64, int, error) {
+	if !r.ResumeFrom.IsZero() {
+		err := r.checkResumeToken(r.ResumeFrom)
+		if err != nil {
+			return -1, 0, err
+		}
 
-	scanner := r.newScanner(r.input)
-	pos := int64(-1)
+		return r.ResumeFrom.offset, r.ResumeFrom.line, nil
+	}
 
-	for scanner.Scan() {
-		attrLine := strings.ToLower(scanner.Text())
-		if keyAttrStr == attrLine {
-			pos = scanner.Position()
-			break
-		}
-		r.Logger.Debug("attrLine \"%s\" does not match key", attrLine)
+	blockPos, err := r.findFirstEntityBlock(r.sectionFrom(0))
+	if err != nil {
+		return -1, 0, err
 	}
 
-	err := scanner.Err()
+	prologueLines, err := r.countLines(0, blockPos)
 	if err != nil {
-		err = merry.Wrap(scanner.Err(), merry.AppendMessagef(
-			"error at position [%d]", scanner.Position(),
-		))
+		return -1, 0, err
 	}
 
-	return pos, err
+	return blockPos, prologueLines + 1, nil
 }
 
-func (r LdifReader) getPrevEntityOffset(input io.ReaderAt, lineOffset int64) (int, error) {
-	scanner := backscanner.New(input, int(lineOffset))
-	for {
-		line, pos, err := scanner.Line()
-		if err != nil {
-			err = merry.Wrap(err, merry.AppendMessagef(
-				"error at position [%d]", pos,
-			))
-			return pos, err
-		}
-
-		if syntax.IsEntityTitle(line) {
-			return pos, nil
-		}
+// getScannerAtFirstEntityBlock returns a scanner positioned where reading
+// starts along with the offset of that position, which the scanner's
+// positions are relative to, and the number of the line there.
+func (r LdifReader) getScannerAtFirstEntityBlock() (Scanner, int64, int, error) {
+	blockPos, firstLine, err := r.firstBlockPosition()
+	if err != nil {
+		return nil, -1, 0, err
 	}
+
+	return r.scannerAt(blockPos), blockPos, firstLine, nil
 }
 
 // ReadEntity returns an empty Entity object if the object is not found,
 // other wise it returns the entity object or an error if one is encountered.
+// Keys are matched against the parsed attributes of each entity, using the
+// configured KeyMatchRule. Only the first matching entity is returned. If
+// ErrOnAmbiguousKey is set, ErrAmbiguousKey is returned when more than one
+// entity matches.
 func (r LdifReader) ReadEntity(keyAttrName string, keyAttrVal string) (e entity.Entity, err error) {
-	keyAttr := entity.NewEntityAttribute(keyAttrName, keyAttrVal)
-
-	keyAttrOffset, err := r.getKeyAttrOffset(keyAttr)
+	matcher, err := newKeyMatcher(keyAttrName, r.KeyMatchRule, keyAttrVal)
 	if err != nil {
 		return
 	}
-	r.Logger.Debug("key found at position: %d", keyAttrOffset)
+	r.Logger.Info("searching with key: %s", matcher)
 
-	// Object not found
-	if keyAttrOffset == -1 {
-		return
+	var firstDN string
+	found := false
+
+	scanErr := r.scanKeyMatches(matcher, func(block entityBlock, matched []string) error {
+		if found {
+			r.Logger.Info("entity %s also matches key", firstDN)
+			return merry.Wrap(ErrAmbiguousKey, merry.AppendMessagef(
+				"first match: %s, next match at position [%d]", firstDN, block.start,
+			))
+		}
+
+		r.Logger.Info("entity found at position: %d", block.start)
+		e, err = r.readSingleEntity(block.lines)
+		if err != nil {
+			return err
+		}
+
+		firstDN, _ = e.GetDN()
+		found = true
+
+		if !r.ErrOnAmbiguousKey {
+			return errStopScan
+		}
+		return nil
+	})
+
+	if scanErr != nil {
+		return entity.Entity{}, scanErr
 	}
 
-	entityOffset, err := r.getPrevEntityOffset(r.input, keyAttrOffset)
-	if err != nil {
-		return
+	return
+}
+
+// EntityMeta describes where in the input an entity was read from.
+type EntityMeta struct {
+	// Offset is the byte offset of the start of the entity's record,
+	// including its title comment
+	Offset int64
+
+	// Length is the size of the record in bytes, so that it can be
+	// read again with io.NewSectionReader(input, Offset, Length)
+	Length int64
+
+	// Line is the number of the record's first line, counting from one
+	Line int
+
+	// Title is the text of the comment that starts the record, which
+	// ldapsearch writes as a title, or empty if there is none
+	Title string
+
+	// Source is the SourceName of the reader
+	Source string
+}
+
+// blockMeta returns the metadata of `block`. Blocks that
+// could not be read only carry the name of the source.
+func (r LdifReader) blockMeta(block entityBlock) EntityMeta {
+	meta := EntityMeta{Source: r.SourceName}
+	if !block.found {
+		return meta
 	}
-	r.Logger.Info("entity found at position: %d", entityOffset)
 
-	_, err = r.input.Seek(int64(entityOffset), 0)
-	if err != nil {
-		return
+	meta.Offset = block.start
+	meta.Length = block.end - block.start
+	meta.Line = block.line
+
+	if len(block.lines) > 0 && syntax.IsLdifComment(block.lines[0]) {
+		meta.Title = strings.TrimSpace(strings.TrimPrefix(block.lines[0], "#"))
 	}
 
-	r.Logger.Info("parsing entity from block")
-	entityScanner := r.newScanner(r.input)
-	return r.getEntityFromBlock(entityScanner)
+	return meta
 }
 
 type EntityResp struct {
 	Entity entity.Entity
 	Error  error
+	Meta   EntityMeta
+
+	// Resume continues reading after this entity when set as the
+	// ResumeFrom of a reader. It is zero if the entity could not be read.
+	Resume ResumeToken
 }
 
 // ReadEntities constructs an ldap entity per entry in the input ldif file.
@@ -206,9 +531,9 @@ func (r LdifReader) ReadEntities() []EntityResp {
 	return entities
 }
 
-func (r LdifReader) readSingleEntity(scanner Scanner) (e entity.Entity, err error) {
+func (r LdifReader) readSingleEntity(entityLines []string) (e entity.Entity, err error) {
 	r.Logger.Info("parsing entity")
-	e, err = r.getEntityFromBlock(scanner)
+	e, err = entitybuilder.BuildEntity(entityLines, r.AttributeFilter)
 	if err != nil {
 		return
 	}
@@ -223,11 +548,82 @@ func (r LdifReader) readSingleEntity(scanner Scanner) (e entity.Entity, err erro
 	return
 }
 
+// buildEntityResp parses the entity in `block`, unless
+// reading the block failed with `err`, and describes it.
+func (r LdifReader) buildEntityResp(block entityBlock, err error) EntityResp {
+	var e entity.Entity
+	if err == nil {
+		e, err = r.readSingleEntity(block.lines)
+	}
+
+	return EntityResp{e, err, r.blockMeta(block), blockResumeToken(block)}
+}
+
+// scanEntityBlocks calls `handleBlock` with the lines of each entity block
+// in the input, or with the error encountered while reading it. Scanning
+// stops once the input is exhausted, a read error occurs or `handleBlock`
+// returns false.
+func (r LdifReader) scanEntityBlocks(handleBlock func(block entityBlock, err error) bool) {
+	r.Logger.Info("finding first entity block")
+	scanner, blockPos, firstLine, err := r.getScannerAtFirstEntityBlock()
+	if err != nil {
+		handleBlock(entityBlock{}, err)
+		return
+	}
+
+	r.scanBlocks(scanner, blockPos, firstLine, newLineFilter(r.AttributeFilter), handleBlock)
+}
+
+// scanBlocks calls `handleBlock` for each entity block read by `scanner`,
+// dropping the lines rejected by `filter` and stopping under the same
+// conditions as scanEntityBlocks. `base` is the offset of the scanner's
+// start, which is added to the offsets of each block, and `firstLine` is
+// the number of the scanner's first line. The lines slice of a block is
+// reused between blocks and must not be retained by `handleBlock`, though
+// the strings it holds may be.
+func (r LdifReader) scanBlocks(scanner Scanner, base int64, firstLine int, filter *lineFilter, handleBlock func(block entityBlock, err error) bool) {
+	buf := r.newBlockBuffer()
+	for {
+		block, err := r.readEntityBlock(scanner, filter, buf)
+		if err == nil && !block.found {
+			return
+		}
+
+		if err == nil && !block.isEntity {
+			r.Logger.Debug("skipping block without an entity")
+			continue
+		}
+
+		block.start += base
+		block.end += base
+		block.line += firstLine
+		block.nextLine += firstLine
+		shouldContinue := handleBlock(block, err)
+
+		if err != nil && err == bufio.ErrTooLong {
+			err = merry.Wrap(err, merry.WithMessagef(
+				"panic caused by line at position: %d", scanner.Position(),
+			))
+			panic(err)
+		}
+
+		// the scanner can't recover from read errors
+		if scanner.Err() != nil || !shouldContinue {
+			return
+		}
+	}
+}
+
 // ReadEntitiesChanneled constructs an ldap entity per entry in the input ldif file
 // and returns the result via a channel. Any errors during processing will be packaged
-// with the entity causing them and returned over the channel. Overflowing the scan buffer
-// (line too long) will corrupt the scanner, causing a panic.
+// with the entity causing them and returned over the channel. Closing `interrupt` stops
+// reading and closes the channel. Overflowing the scan buffer (line too long) will
+// corrupt the scanner, causing a panic.
 func (r LdifReader) ReadEntitiesChanneled(interrupt <-chan bool) <-chan EntityResp {
+	if r.Parallelism > 1 {
+		return r.readEntitiesParallel(interrupt)
+	}
+
 	results := make(chan EntityResp)
 
 	go func() {
@@ -239,34 +635,65 @@ func (r LdifReader) ReadEntitiesChanneled(interrupt <-chan bool) <-chan EntityRe
 		default:
 		}
 
-		r.Logger.Info("finding first entity block")
-		scanner, err := r.getScannerAtFirstEntityBlock()
-		if err != nil {
-			resp := EntityResp{Error: err}
-			results <- resp
-			return
-		}
+		r.scanEntityBlocks(func(block entityBlock, err error) bool {
+			resp := r.buildEntityResp(block, err)
+			select {
+			case results <- resp:
+			case <-interrupt:
+				return false
+			}
 
-		hasNextEntity := true
-		for hasNextEntity {
-			e, err := r.readSingleEntity(scanner)
+			return resp.Error == nil || r.ContinueOnErr
+		})
+	}()
 
-			resp := EntityResp{e, err}
-			results <- resp
+	return results
+}
 
-			if err != nil && err == bufio.ErrTooLong {
-				err = merry.Wrap(err, merry.WithMessagef(
-					"panic caused by line at position: %d", scanner.Position(),
-				))
-				panic(err)
-			}
+type RecordResp struct {
+	Record entitybuilder.Record
+	Error  error
+	Meta   EntityMeta
+	Resume ResumeToken
+}
 
-			hasNextEntity = scanner.Scan()
+// ReadRecordsChanneled constructs an ordered Record per entry in the input ldif
+// file and returns the result via a channel. Records preserve the comments,
+// attribute order, value order, name casing, value encodings and source
+// lines of the input, so that WriteRecord can write them back unchanged.
+// Errors are handled the same way as in ReadEntitiesChanneled.
+func (r LdifReader) ReadRecordsChanneled(interrupt <-chan bool) <-chan RecordResp {
+	results := make(chan RecordResp)
 
-			if err != nil && !r.ContinueOnErr {
-				return
-			}
+	go func() {
+		defer close(results)
+
+		select {
+		case <-interrupt:
+			return
+		default:
 		}
+
+		r.scanEntityBlocks(func(block entityBlock, err error) bool {
+			var rec entitybuilder.Record
+			var raw []byte
+			if err == nil {
+				raw, err = r.rawBytes(block.start, block.end)
+			}
+			if err == nil {
+				r.Logger.Info("parsing record")
+				rec, err = entitybuilder.BuildRecordFromText(string(raw), r.AttributeFilter)
+			}
+
+			resp := RecordResp{rec, err, r.blockMeta(block), blockResumeToken(block)}
+			select {
+			case results <- resp:
+			case <-interrupt:
+				return false
+			}
+
+			return err == nil || r.ContinueOnErr
+		})
 	}()
 
 	return results
@@ -2,12 +2,14 @@ package ldifparser_test
 
 import (
 	"bufio"
+	"io"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -200,10 +202,214 @@ func TestReader_ContinueOnErr(t *testing.T) {
 	res := ldifReader.ReadEntities()
 	r.Len(res, 3)
 
+	// reading stops at the malformed second entity
 	conf = ldifparser.NewReaderConf()
 	conf.ContinueOnErr = false
 	ldifReader = ldifparser.NewLdifReader(testFile, conf)
 
 	res = ldifReader.ReadEntities()
-	r.Len(res, 1)
+	r.Len(res, 2)
+	r.NoError(res[0].Error)
+	r.Error(res[1].Error)
+}
+
+func TestReader_ReadEntitiesWithoutTitles(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"version: 1",
+		"",
+		"dn: CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com",
+		"cn: MYUSR",
+		"",
+		"",
+		"dn: CN=MYPC,OU=ContosoUsers,DC=contoso,DC=com",
+		"cn: MYPC",
+		"",
+		"# search result",
+		"search: 2",
+		"result: 0 Success",
+		"",
+		"# numResponses: 3",
+		"# numEntries: 2",
+	}, "\n")
+
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(input))
+	entities := ldifReader.ReadEntities()
+	r.Len(entities, 2)
+
+	for i, name := range []string{"MYUSR", "MYPC"} {
+		r.NoError(entities[i].Error)
+		cn, found := entities[i].Entity.GetSingleValuedAttribute("cn")
+		r.True(found)
+		r.Equal(name, cn)
+	}
+}
+
+func TestReader_AttrFilterSkipsFoldedLines(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"dn: CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com",
+		"# a comment: with a colon",
+		"thumbnailPhoto:: AAAA",
+		" BBBB",
+		" CCCC",
+		"sAMAccountName: MY",
+		" USR",
+		"memberOf: CN=Group1,DC=contoso,DC=com",
+		"",
+	}, "\n")
+
+	conf := ldifparser.NewReaderConf()
+	conf.AttributeFilter = entitybuilder.NewAttributeFilter("sAMAccountName")
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(input), conf)
+
+	entities := ldifReader.ReadEntities()
+	r.Len(entities, 1)
+	r.NoError(entities[0].Error)
+
+	e := entities[0].Entity
+	r.Equal(2, e.Size())
+
+	name, found := e.GetSingleValuedAttribute("sAMAccountName")
+	r.True(found)
+	r.Equal("MYUSR", name)
+}
+
+func TestReader_UnfoldsLinesAcrossEntities(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"dn: CN=USR1,DC=contoso,DC=com",
+		"description: first line",
+		"  continued: here",
+		"cn: USR1",
+		"",
+		"dn: CN=USR2,",
+		" DC=contoso,DC=com",
+		"cn: U",
+		" S",
+		" R2",
+		"",
+	}, "\n")
+
+	entities := ldifparser.NewLdifReader(strings.NewReader(input)).ReadEntities()
+	r.Len(entities, 2)
+
+	for _, resp := range entities {
+		r.NoError(resp.Error)
+	}
+
+	desc, _ := entities[0].Entity.GetSingleValuedAttribute("description")
+	r.Equal("first line continued: here", desc)
+
+	cn, _ := entities[0].Entity.GetSingleValuedAttribute("cn")
+	r.Equal("USR1", cn)
+
+	dn, _ := entities[1].Entity.GetDN()
+	r.Equal("CN=USR2,DC=contoso,DC=com", dn)
+
+	cn, _ = entities[1].Entity.GetSingleValuedAttribute("cn")
+	r.Equal("USR2", cn)
+}
+
+func TestReader_EntityMeta(t *testing.T) {
+	r := require.New(t)
+
+	testFilePath := filepath.Join(getTestDataDir(), testFileName)
+	testFile, err := os.Open(testFilePath)
+	r.NoError(err)
+	defer testFile.Close()
+
+	conf := ldifparser.NewReaderConf()
+	conf.SourceName = testFileName
+	entities := ldifparser.NewLdifReader(testFile, conf).ReadEntities()
+	r.Len(entities, numTestFileEntities)
+
+	wantLines := []int{10, 49, 88}
+	wantTitles := []string{
+		"MYUSR, ContosoUsers, contoso.com",
+		"DISABLEDUSER, ContosoUsers, contoso.com",
+		"MYPC, ContosoUsers, contoso.com",
+	}
+
+	for i, resp := range entities {
+		r.NoError(resp.Error)
+
+		r.Equal(wantLines[i], resp.Meta.Line)
+		r.Equal(wantTitles[i], resp.Meta.Title)
+		r.Equal(testFileName, resp.Meta.Source)
+
+		// the record can be read again on its own
+		record := io.NewSectionReader(testFile, resp.Meta.Offset, resp.Meta.Length)
+		reread := ldifparser.NewLdifReader(record).ReadEntities()
+		r.Len(reread, 1)
+		r.NoError(reread[0].Error)
+
+		dn, _ := resp.Entity.GetDN()
+		rereadDN, _ := reread[0].Entity.GetDN()
+		r.Equal(dn, rereadDN)
+	}
+}
+
+func TestReader_EntityMetaWithoutTitles(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"version: 1",
+		"",
+		"dn: CN=USR1,DC=contoso,DC=com",
+		"description: folded",
+		" line",
+		"",
+		"",
+		"dn: CN=USR2,DC=contoso,DC=com",
+		"",
+	}, "\n")
+
+	entities := ldifparser.NewLdifReader(strings.NewReader(input)).ReadEntities()
+	r.Len(entities, 2)
+
+	r.Equal(ldifparser.EntityMeta{Offset: 12, Length: 56, Line: 3}, entities[0].Meta)
+	r.Equal(ldifparser.EntityMeta{Offset: 70, Length: 30, Line: 8}, entities[1].Meta)
+	r.Equal("dn: CN=USR2,DC=contoso,DC=com\n", input[70:70+30])
+}
+
+// dnlessRecordLdif holds a search reference, which has no DN,
+// between two entities, as in the output of ldapsearch -LLL
+var dnlessRecordLdif = strings.Join([]string{
+	"dn: CN=USR1,DC=contoso,DC=com",
+	"cn: USR1",
+	"sAMAccountName: USR1",
+	"",
+	"ref: ldap://contoso.com/CN=Configuration,DC=contoso,DC=com",
+	"",
+	"dn: CN=USR2,DC=contoso,DC=com",
+	"cn: USR2",
+	"sAMAccountName: USR2",
+	"",
+}, "\n")
+
+func TestReader_AttrFilterWithDNlessRecord(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewReaderConf()
+	conf.AttributeFilter = entitybuilder.NewAttributeFilter("sAMAccountName")
+
+	for _, ldifReader := range []ldifparser.LdifReader{
+		ldifparser.NewLdifReader(strings.NewReader(dnlessRecordLdif), conf),
+		ldifparser.NewLdifReader(strings.NewReader(dnlessRecordLdif)),
+	} {
+		entities := ldifReader.ReadEntities()
+		r.Len(entities, 3)
+
+		r.NoError(entities[0].Error)
+		r.Error(entities[1].Error)
+		r.Equal(5, entities[1].Meta.Line)
+		r.NoError(entities[2].Error)
+
+		name, _ := entities[2].Entity.GetSingleValuedAttribute("sAMAccountName")
+		r.Equal("USR2", name)
+	}
 }
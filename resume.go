@@ -0,0 +1,117 @@
+package ldifparser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// ErrInvalidResumeToken is returned when a ResumeToken can't be
+// parsed or does not point to the start of a line in the input.
+var ErrInvalidResumeToken = merry.New("invalid resume token")
+
+// resumeTokenVersion is written into each token so that
+// the format can change without misreading old tokens
+const resumeTokenVersion int = 1
+
+// ResumeToken marks the position in the input just after an entity, so
+// that reading can be continued from the entity that follows it. Tokens
+// are opaque; they can be stored with String or MarshalText and restored
+// with ParseResumeToken or UnmarshalText. The zero value resumes nothing.
+//
+// Tokens only resume correctly when the entities before them have all
+// been processed, so tokens from parallel reads without PreserveOrder
+// should not be used as checkpoints.
+type ResumeToken struct {
+	offset int64
+	line   int
+}
+
+// blockResumeToken returns the token that resumes reading after `block`.
+// Blocks that could not be read have no token.
+func blockResumeToken(block entityBlock) ResumeToken {
+	if !block.found {
+		return ResumeToken{}
+	}
+
+	return ResumeToken{offset: block.end, line: block.nextLine}
+}
+
+// IsZero returns true for the zero ResumeToken.
+func (t ResumeToken) IsZero() bool {
+	return t.line == 0
+}
+
+func (t ResumeToken) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	state := fmt.Sprintf("%d:%d:%d", resumeTokenVersion, t.offset, t.line)
+	return base64.RawURLEncoding.EncodeToString([]byte(state))
+}
+
+// ParseResumeToken restores a token saved with String.
+// The empty string is parsed as the zero ResumeToken.
+func ParseResumeToken(s string) (ResumeToken, error) {
+	if s == "" {
+		return ResumeToken{}, nil
+	}
+
+	state, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ResumeToken{}, merry.Wrap(ErrInvalidResumeToken, merry.WithCause(err))
+	}
+
+	var version int
+	var t ResumeToken
+
+	_, err = fmt.Sscanf(string(state), "%d:%d:%d", &version, &t.offset, &t.line)
+	isValid := err == nil && version == resumeTokenVersion && t.offset >= 0 && t.line > 0
+	if !isValid || t.String() != s {
+		return ResumeToken{}, merry.Wrap(ErrInvalidResumeToken, merry.AppendMessagef("malformed token %q", s))
+	}
+
+	return t, nil
+}
+
+func (t ResumeToken) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ResumeToken) UnmarshalText(text []byte) error {
+	parsed, err := ParseResumeToken(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+	return nil
+}
+
+// checkResumeToken makes sure that the token points to the start
+// of a line, or the end, of the input. This catches most tokens
+// that were taken from a different input.
+func (r LdifReader) checkResumeToken(t ResumeToken) error {
+	if t.offset == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 2)
+	n, err := r.input.ReadAt(buf, t.offset-1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	atLineStart := n == 2 && buf[0] == '\n'
+	atInputEnd := n == 1
+	if !atLineStart && !atInputEnd {
+		return merry.Wrap(ErrInvalidResumeToken, merry.AppendMessagef(
+			"position [%d] is not the start of a line", t.offset,
+		))
+	}
+
+	return nil
+}
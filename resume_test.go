@@ -0,0 +1,120 @@
+package ldifparser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kgoins/ldifparser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResume_ReadEntitiesFromToken(t *testing.T) {
+	r := require.New(t)
+
+	ldifReader := openTestReader(t, testFileName)
+	entities := ldifReader.ReadEntities()
+	r.Len(entities, numTestFileEntities)
+
+	token, err := ldifparser.ParseResumeToken(entities[0].Resume.String())
+	r.NoError(err)
+	r.Equal(entities[0].Resume, token)
+
+	conf := ldifparser.NewReaderConf()
+	conf.ResumeFrom = token
+	resumed := openTestReader(t, testFileName)
+	resumed.ReaderConf = conf
+
+	rest := resumed.ReadEntities()
+	r.Len(rest, numTestFileEntities-1)
+
+	for i, resp := range rest {
+		r.NoError(resp.Error)
+		r.Equal(entities[i+1].Meta, resp.Meta)
+		r.Equal(entities[i+1].Resume, resp.Resume)
+	}
+
+	// lookups and counts ignore the token
+	e, err := resumed.ReadEntity("sAMAccountName", "MYUSR")
+	r.NoError(err)
+	r.False(e.IsEmpty())
+
+	found, missing, err := resumed.ReadEntitiesByKeys("sAMAccountName", []string{"MYUSR"})
+	r.NoError(err)
+	r.Len(found, 1)
+	r.Empty(missing)
+
+	count, err := resumed.CountEntities()
+	r.NoError(err)
+	r.Equal(numTestFileEntities, count)
+
+	// nothing is left after the last entity
+	resumed.ResumeFrom = entities[numTestFileEntities-1].Resume
+	r.Empty(resumed.ReadEntities())
+}
+
+func TestResume_Parallel(t *testing.T) {
+	r := require.New(t)
+	ldif := generateLdif(400, 2048)
+
+	entities := ldifparser.NewLdifReader(bytes.NewReader(ldif)).ReadEntities()
+
+	conf := ldifparser.NewReaderConf()
+	conf.Parallelism = 4
+	conf.ResumeFrom = entities[99].Resume
+
+	rest := ldifparser.NewLdifReader(bytes.NewReader(ldif), conf).ReadEntities()
+	r.Len(rest, 300)
+
+	for i, resp := range rest {
+		r.NoError(resp.Error)
+		r.Equal(entities[i+100].Meta, resp.Meta)
+	}
+}
+
+func TestResume_TextMarshaling(t *testing.T) {
+	r := require.New(t)
+
+	entities := openTestReader(t, testFileName).ReadEntities()
+
+	type checkpoint struct {
+		Resume ldifparser.ResumeToken
+	}
+
+	saved, err := json.Marshal(checkpoint{entities[1].Resume})
+	r.NoError(err)
+
+	var restored checkpoint
+	r.NoError(json.Unmarshal(saved, &restored))
+	r.Equal(entities[1].Resume, restored.Resume)
+
+	var zero ldifparser.ResumeToken
+	r.True(zero.IsZero())
+	r.Empty(zero.String())
+}
+
+func TestResume_InvalidToken(t *testing.T) {
+	r := require.New(t)
+
+	for _, malformed := range []string{"not a token", "MTo1", "MjoxOjE"} {
+		_, err := ldifparser.ParseResumeToken(malformed)
+		r.ErrorIs(err, ldifparser.ErrInvalidResumeToken, malformed)
+	}
+
+	entities := openTestReader(t, testFileName).ReadEntities()
+
+	// a token taken from another input
+	input := "dn: CN=USR1,DC=contoso,DC=com\ncn: USR1\n"
+	conf := ldifparser.NewReaderConf()
+	conf.ResumeFrom = entities[0].Resume
+
+	results := ldifparser.NewLdifReader(strings.NewReader(input), conf).ReadEntities()
+	r.Len(results, 1)
+	r.ErrorIs(results[0].Error, ldifparser.ErrInvalidResumeToken)
+
+	// lookups don't use the token, so it isn't checked
+	e, err := ldifparser.NewLdifReader(strings.NewReader(input), conf).ReadEntity("cn", "USR1")
+	r.NoError(err)
+	r.False(e.IsEmpty())
+}
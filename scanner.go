@@ -0,0 +1,145 @@
+package ldifparser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+const initialScanBufferSize int = 64 * 1024
+
+// positionedScanner is a line scanner that tracks the byte offset
+// of the end of the most recently scanned line. Unlike poscanner,
+// it exposes the raw line bytes so that lines can be inspected
+// without allocating a string.
+type positionedScanner struct {
+	pos     int64
+	scanner *bufio.Scanner
+}
+
+// newPositionedScanner constructs a scanner that can hold lines
+// of up to `maxLineSize` bytes. The buffer starts small and grows
+// as longer lines are encountered.
+func newPositionedScanner(input io.Reader, maxLineSize int) *positionedScanner {
+	ps := &positionedScanner{
+		scanner: bufio.NewScanner(input),
+	}
+
+	bufSize := initialScanBufferSize
+	if maxLineSize < bufSize {
+		bufSize = maxLineSize
+	}
+
+	// small inputs of a known size don't need a full buffer
+	if sized, isSized := input.(interface{ Size() int64 }); isSized && sized.Size() < int64(bufSize) {
+		bufSize = int(sized.Size()) + 1
+	}
+	ps.scanner.Buffer(make([]byte, bufSize), maxLineSize)
+
+	ps.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		ps.pos += int64(advance)
+		return advance, token, err
+	})
+
+	return ps
+}
+
+func (ps *positionedScanner) Scan() bool {
+	return ps.scanner.Scan()
+}
+
+// Bytes returns the most recent line. The slice is only
+// valid until the next call to Scan.
+func (ps *positionedScanner) Bytes() []byte {
+	return ps.scanner.Bytes()
+}
+
+func (ps *positionedScanner) Text() string {
+	return ps.scanner.Text()
+}
+
+func (ps *positionedScanner) Err() error {
+	return ps.scanner.Err()
+}
+
+// Position returns the byte offset of the end of the most recent line
+func (ps *positionedScanner) Position() int64 {
+	return ps.pos
+}
+
+// bytesScanner is a line scanner over an in-memory input, such as a
+// memory mapped file. Lines are returned as slices of the input, so
+// scanning copies nothing. Like positionedScanner, positions are
+// relative to the start of the scanned data and lines longer than
+// the maximum line size fail with bufio.ErrTooLong.
+type bytesScanner struct {
+	data        []byte
+	base        int64
+	maxLineSize int
+
+	pos   int
+	start int
+	line  []byte
+	err   error
+}
+
+// newBytesScanner constructs a scanner over `data`, which
+// starts at offset `base` of the whole input.
+func newBytesScanner(data []byte, base int64, maxLineSize int) *bytesScanner {
+	return &bytesScanner{
+		data:        data,
+		base:        base,
+		maxLineSize: maxLineSize,
+	}
+}
+
+func (bs *bytesScanner) Scan() bool {
+	bs.line = nil
+	if bs.err != nil || bs.pos >= len(bs.data) {
+		return false
+	}
+
+	rest := bs.data[bs.pos:]
+	lineLen := bytes.IndexByte(rest, '\n')
+	advance := lineLen + 1
+	if lineLen < 0 {
+		lineLen = len(rest)
+		advance = lineLen
+	}
+
+	if advance > bs.maxLineSize {
+		bs.err = bufio.ErrTooLong
+		return false
+	}
+
+	bs.line = bytes.TrimSuffix(rest[:lineLen], []byte{'\r'})
+	bs.start = bs.pos
+	bs.pos += advance
+	return true
+}
+
+// Bytes returns the most recent line, which
+// shares memory with the scanned data.
+func (bs *bytesScanner) Bytes() []byte {
+	return bs.line
+}
+
+func (bs *bytesScanner) Text() string {
+	return string(bs.line)
+}
+
+func (bs *bytesScanner) Err() error {
+	return bs.err
+}
+
+// Position returns the byte offset of the end of the most recent line
+func (bs *bytesScanner) Position() int64 {
+	return int64(bs.pos)
+}
+
+// lineOffset returns the offset of the most
+// recent line within the whole input.
+func (bs *bytesScanner) lineOffset() int64 {
+	return bs.base + int64(bs.start)
+}
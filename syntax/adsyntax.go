@@ -0,0 +1,154 @@
+package syntax
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const guidSize int = 16
+
+// ParseGUID converts the string form of an Active Directory objectGUID,
+// ex) {0f5fe0ec-275d-4442-9561-8f141c257669}, into its binary form.
+// Braces are optional. The first three fields are stored little-endian.
+func ParseGUID(guid string) ([]byte, error) {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(guid, "{"), "}")
+
+	fields := strings.Split(trimmed, "-")
+	fieldLens := []int{8, 4, 4, 4, 12}
+	if len(fields) != len(fieldLens) {
+		return nil, fmt.Errorf("malformed GUID: %s", guid)
+	}
+
+	guidBytes := make([]byte, 0, guidSize)
+	for i, field := range fields {
+		fieldBytes, err := hex.DecodeString(field)
+		if err != nil || len(field) != fieldLens[i] {
+			return nil, fmt.Errorf("malformed GUID: %s", guid)
+		}
+
+		// Data1, Data2 and Data3 are little-endian
+		if i < 3 {
+			reverseBytes(fieldBytes)
+		}
+		guidBytes = append(guidBytes, fieldBytes...)
+	}
+
+	return guidBytes, nil
+}
+
+// FormatGUID converts a binary objectGUID into its string form,
+// ex) 0f5fe0ec-275d-4442-9561-8f141c257669
+func FormatGUID(guidBytes []byte) (string, error) {
+	if len(guidBytes) != guidSize {
+		return "", fmt.Errorf("GUID must be %d bytes, found %d", guidSize, len(guidBytes))
+	}
+
+	return fmt.Sprintf(
+		"%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(guidBytes[0:4]),
+		binary.LittleEndian.Uint16(guidBytes[4:6]),
+		binary.LittleEndian.Uint16(guidBytes[6:8]),
+		guidBytes[8:10],
+		guidBytes[10:],
+	), nil
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// maxSubAuthorities is the most sub-authorities a SID may hold
+const maxSubAuthorities int = 15
+
+// ParseSID converts the string form of a security identifier,
+// ex) S-1-5-21-73586283-854245398-1428897155-795234, into the
+// binary form used by objectSid. Identifier authorities may be
+// written in decimal or as 0x prefixed hex.
+func ParseSID(sid string) ([]byte, error) {
+	parts := strings.Split(sid, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return nil, fmt.Errorf("malformed SID: %s", sid)
+	}
+
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("malformed SID revision: %s", sid)
+	}
+
+	authorityStr, base := parts[2], 10
+	if strings.HasPrefix(authorityStr, "0x") || strings.HasPrefix(authorityStr, "0X") {
+		authorityStr, base = authorityStr[2:], 16
+	}
+
+	authority, err := strconv.ParseUint(authorityStr, base, 48)
+	if err != nil {
+		return nil, fmt.Errorf("malformed SID authority: %s", sid)
+	}
+
+	subAuthorities := parts[3:]
+	if len(subAuthorities) > maxSubAuthorities {
+		return nil, fmt.Errorf("SID has too many sub-authorities: %s", sid)
+	}
+
+	sidBytes := make([]byte, 8, 8+4*len(subAuthorities))
+	sidBytes[0] = byte(revision)
+	sidBytes[1] = byte(len(subAuthorities))
+
+	// the identifier authority is a 48 bit big-endian value
+	for i := 0; i < 6; i++ {
+		sidBytes[2+i] = byte(authority >> uint(8*(5-i)))
+	}
+
+	for _, part := range subAuthorities {
+		subAuthority, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed SID sub-authority: %s", sid)
+		}
+
+		subAuthBytes := make([]byte, 4)
+		binary.LittleEndian.PutUint32(subAuthBytes, uint32(subAuthority))
+		sidBytes = append(sidBytes, subAuthBytes...)
+	}
+
+	return sidBytes, nil
+}
+
+// FormatSID converts a binary objectSid into its string form.
+func FormatSID(sidBytes []byte) (string, error) {
+	if len(sidBytes) < 8 {
+		return "", errors.New("SID is too short")
+	}
+
+	numSubAuths := int(sidBytes[1])
+	if len(sidBytes) != 8+4*numSubAuths {
+		return "", fmt.Errorf("SID with %d sub-authorities must be %d bytes", numSubAuths, 8+4*numSubAuths)
+	}
+
+	authority := uint64(0)
+	for i := 2; i < 8; i++ {
+		authority = authority<<8 | uint64(sidBytes[i])
+	}
+
+	var sid strings.Builder
+	fmt.Fprintf(&sid, "S-%d-", sidBytes[0])
+
+	// authorities that don't fit in 32 bits are written in hex
+	if authority >= 1<<32 {
+		fmt.Fprintf(&sid, "0x%012X", authority)
+	} else {
+		sid.WriteString(strconv.FormatUint(authority, 10))
+	}
+
+	for i := 0; i < numSubAuths; i++ {
+		offset := 8 + 4*i
+		fmt.Fprintf(&sid, "-%d", binary.LittleEndian.Uint32(sidBytes[offset:offset+4]))
+	}
+
+	return sid.String(), nil
+}
@@ -0,0 +1,343 @@
+package syntax
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AVA is a single attribute type and value pair within an RDN.
+// The value is held unescaped.
+type AVA struct {
+	Type  string
+	Value string
+}
+
+// RDN is a relative distinguished name made of one or more AVAs.
+type RDN struct {
+	AVAs []AVA
+}
+
+// DN is an RFC 4514 distinguished name. RDNs are ordered from
+// the entry's own RDN to the RDN closest to the root.
+type DN struct {
+	RDNs []RDN
+}
+
+func isTypeChar(c byte) bool {
+	return ('a' <= c && c <= 'z') ||
+		('A' <= c && c <= 'Z') ||
+		('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == ';'
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func isDNSeparator(c byte) bool {
+	return c == ',' || c == '+' || c == ';'
+}
+
+// parseAttrType reads an attribute type ending at the next `=`
+// and returns it along with the position of the value.
+func parseAttrType(dnStr string, start int) (string, int, error) {
+	eqIdx := strings.IndexByte(dnStr[start:], '=')
+	if eqIdx < 0 {
+		return "", 0, errors.New("missing `=` in DN component: " + dnStr[start:])
+	}
+
+	attrType := strings.TrimSpace(dnStr[start : start+eqIdx])
+	if attrType == "" {
+		return "", 0, errors.New("empty attribute type in DN: " + dnStr)
+	}
+
+	for i := 0; i < len(attrType); i++ {
+		if !isTypeChar(attrType[i]) {
+			return "", 0, errors.New("invalid attribute type in DN: " + attrType)
+		}
+	}
+
+	return attrType, start + eqIdx + 1, nil
+}
+
+// parseAttrValue reads an escaped or quoted attribute value starting
+// at `start` and returns the unescaped value along with the position
+// of the separator that ends it, or the end of the DN.
+func parseAttrValue(dnStr string, start int) (string, int, error) {
+	var val strings.Builder
+
+	i := start
+	for i < len(dnStr) && dnStr[i] == ' ' {
+		i++
+	}
+
+	if i < len(dnStr) && dnStr[i] == '"' {
+		return parseQuotedValue(dnStr, i)
+	}
+
+	// length of the value before any unescaped trailing spaces
+	keepLen := 0
+
+	for ; i < len(dnStr); i++ {
+		c := dnStr[i]
+
+		if isDNSeparator(c) {
+			break
+		}
+
+		if c != '\\' {
+			val.WriteByte(c)
+			if c != ' ' {
+				keepLen = val.Len()
+			}
+			continue
+		}
+
+		if i+1 >= len(dnStr) {
+			return "", 0, errors.New("malformed escape sequence in DN: " + dnStr)
+		}
+
+		if i+2 < len(dnStr) && isHexDigit(dnStr[i+1]) && isHexDigit(dnStr[i+2]) {
+			b, _ := strconv.ParseUint(dnStr[i+1:i+3], 16, 8)
+			val.WriteByte(byte(b))
+			i += 2
+		} else {
+			val.WriteByte(dnStr[i+1])
+			i++
+		}
+		keepLen = val.Len()
+	}
+
+	return val.String()[:keepLen], i, nil
+}
+
+// parseQuotedValue reads a legacy RFC 2253 quoted value
+// beginning with the quote at `start`.
+func parseQuotedValue(dnStr string, start int) (string, int, error) {
+	var val strings.Builder
+
+	i := start + 1
+	for ; i < len(dnStr) && dnStr[i] != '"'; i++ {
+		if dnStr[i] == '\\' && i+1 < len(dnStr) {
+			i++
+		}
+		val.WriteByte(dnStr[i])
+	}
+
+	if i >= len(dnStr) {
+		return "", 0, errors.New("unterminated quoted value in DN: " + dnStr)
+	}
+
+	// skip the closing quote and any spaces before the separator
+	i++
+	for i < len(dnStr) && dnStr[i] == ' ' {
+		i++
+	}
+
+	if i < len(dnStr) && !isDNSeparator(dnStr[i]) {
+		return "", 0, errors.New("unexpected characters after quoted value in DN: " + dnStr)
+	}
+
+	return val.String(), i, nil
+}
+
+// ParseDN parses an RFC 4514 string representation of a distinguished
+// name. Escaped characters, hex pairs, multi-valued RDNs and legacy
+// quoted values are supported. An empty string is the root DN.
+func ParseDN(dnStr string) (DN, error) {
+	dn := DN{}
+	if strings.TrimSpace(dnStr) == "" {
+		return dn, nil
+	}
+
+	rdn := RDN{}
+	pos := 0
+
+	for {
+		attrType, valStart, err := parseAttrType(dnStr, pos)
+		if err != nil {
+			return DN{}, err
+		}
+
+		val, sepIdx, err := parseAttrValue(dnStr, valStart)
+		if err != nil {
+			return DN{}, err
+		}
+
+		rdn.AVAs = append(rdn.AVAs, AVA{Type: attrType, Value: val})
+
+		if sepIdx >= len(dnStr) {
+			dn.RDNs = append(dn.RDNs, rdn)
+			return dn, nil
+		}
+
+		if dnStr[sepIdx] != '+' {
+			dn.RDNs = append(dn.RDNs, rdn)
+			rdn = RDN{}
+		}
+
+		pos = sepIdx + 1
+	}
+}
+
+// MustParseDN is like ParseDN but panics if the DN is malformed.
+func MustParseDN(dnStr string) DN {
+	dn, err := ParseDN(dnStr)
+	if err != nil {
+		panic(err)
+	}
+
+	return dn
+}
+
+// EscapeDNValue escapes an attribute value for use in a DN string.
+func EscapeDNValue(val string) string {
+	var escaped strings.Builder
+
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+
+		switch {
+		case c == 0:
+			escaped.WriteString(`\00`)
+			continue
+		case strings.IndexByte(`"+,;<>\`, c) >= 0:
+			escaped.WriteByte('\\')
+		case i == 0 && (c == ' ' || c == '#'):
+			escaped.WriteByte('\\')
+		case i == len(val)-1 && c == ' ':
+			escaped.WriteByte('\\')
+		}
+
+		escaped.WriteByte(c)
+	}
+
+	return escaped.String()
+}
+
+// String returns the RFC 4514 form of the AVA.
+func (ava AVA) String() string {
+	return ava.Type + "=" + EscapeDNValue(ava.Value)
+}
+
+// Equal compares AVAs with case-insensitive types and values,
+// as most directory attributes use case-ignore matching.
+func (ava AVA) Equal(other AVA) bool {
+	return strings.EqualFold(ava.Type, other.Type) &&
+		strings.EqualFold(ava.Value, other.Value)
+}
+
+func (ava AVA) canonical() string {
+	return strings.ToLower(ava.Type) + "=" + EscapeDNValue(strings.ToLower(ava.Value))
+}
+
+// String returns the RFC 4514 form of the RDN.
+func (rdn RDN) String() string {
+	avaStrs := make([]string, 0, len(rdn.AVAs))
+	for _, ava := range rdn.AVAs {
+		avaStrs = append(avaStrs, ava.String())
+	}
+
+	return strings.Join(avaStrs, "+")
+}
+
+// Equal returns true if both RDNs hold the same AVAs in any order.
+func (rdn RDN) Equal(other RDN) bool {
+	return rdn.canonical() == other.canonical()
+}
+
+// Get returns the value of the AVA with the given type.
+func (rdn RDN) Get(attrType string) (string, bool) {
+	for _, ava := range rdn.AVAs {
+		if strings.EqualFold(ava.Type, attrType) {
+			return ava.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func (rdn RDN) canonical() string {
+	avaStrs := make([]string, 0, len(rdn.AVAs))
+	for _, ava := range rdn.AVAs {
+		avaStrs = append(avaStrs, ava.canonical())
+	}
+	sort.Strings(avaStrs)
+
+	return strings.Join(avaStrs, "+")
+}
+
+// String returns the RFC 4514 form of the DN, keeping
+// the casing of the parsed types and values.
+func (dn DN) String() string {
+	rdnStrs := make([]string, 0, len(dn.RDNs))
+	for _, rdn := range dn.RDNs {
+		rdnStrs = append(rdnStrs, rdn.String())
+	}
+
+	return strings.Join(rdnStrs, ",")
+}
+
+// CanonicalString returns a normalized form of the DN with lowercase
+// types and values and sorted multi-valued RDNs, suitable for use
+// as a map key.
+func (dn DN) CanonicalString() string {
+	rdnStrs := make([]string, 0, len(dn.RDNs))
+	for _, rdn := range dn.RDNs {
+		rdnStrs = append(rdnStrs, rdn.canonical())
+	}
+
+	return strings.Join(rdnStrs, ",")
+}
+
+// IsEmpty returns true for the root DN.
+func (dn DN) IsEmpty() bool {
+	return len(dn.RDNs) == 0
+}
+
+// RDN returns the entry's own RDN, the leftmost component of the DN.
+func (dn DN) RDN() RDN {
+	if dn.IsEmpty() {
+		return RDN{}
+	}
+
+	return dn.RDNs[0]
+}
+
+// Parent returns the DN of the entry's parent.
+// The parent of the root DN is the root DN.
+func (dn DN) Parent() DN {
+	if dn.IsEmpty() {
+		return dn
+	}
+
+	return DN{RDNs: dn.RDNs[1:]}
+}
+
+// Equal returns true if both DNs have equal RDNs in the same order.
+func (dn DN) Equal(other DN) bool {
+	if len(dn.RDNs) != len(other.RDNs) {
+		return false
+	}
+
+	for i := range dn.RDNs {
+		if !dn.RDNs[i].Equal(other.RDNs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsDescendantOf returns true if the DN is below `ancestor`
+// in the directory tree. A DN is not its own descendant.
+func (dn DN) IsDescendantOf(ancestor DN) bool {
+	offset := len(dn.RDNs) - len(ancestor.RDNs)
+	if offset <= 0 {
+		return false
+	}
+
+	return DN{RDNs: dn.RDNs[offset:]}.Equal(ancestor)
+}
@@ -1,15 +1,14 @@
 package syntax
 
 import (
-	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
-var titleRegex *regexp.Regexp = regexp.MustCompile(`^# .*\.`)
-var attrRegex *regexp.Regexp = regexp.MustCompile(`^[[:alnum:].;-]+:{1,2} \S+$`)
-
+// IsEntityTitle returns true if the line is a comment
+// that contains a period, ex) # MYUSR, Users, contoso.com
 func IsEntityTitle(line string) bool {
-	return titleRegex.MatchString(line)
+	return strings.HasPrefix(line, "# ") && strings.Contains(line[2:], ".")
 }
 
 func IsEntitySeparator(line string) bool {
@@ -25,5 +24,144 @@ func IsLdifAttributeLine(line string) bool {
 		return false
 	}
 
-	return attrRegex.MatchString(line)
+	nameEnd := 0
+	for nameEnd < len(line) && isAttrNameChar(line[nameEnd]) {
+		nameEnd++
+	}
+
+	if nameEnd == 0 || !strings.HasPrefix(line[nameEnd:], ":") {
+		return false
+	}
+
+	val := strings.TrimPrefix(line[nameEnd+1:], ":")
+	if !strings.HasPrefix(val, " ") {
+		return false
+	}
+
+	val = val[1:]
+	return val != "" && !strings.ContainsAny(val, "\t\n\f\r ")
+}
+
+// isAttrNameChar returns true for the characters allowed in the
+// attribute description of an attribute line: letters, digits,
+// hyphens, and the periods and semicolons of OIDs and options.
+func isAttrNameChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+
+	return c == '-' || c == '.' || c == ';'
+}
+
+// isSafeInitChar implements the RFC 2849 SAFE-INIT-CHAR rule,
+// which excludes NUL, LF, CR, SPACE, colon and less-than.
+func isSafeInitChar(c byte) bool {
+	switch c {
+	case 0x00, '\n', '\r', ' ', ':', '<':
+		return false
+	}
+
+	return c < utf8.RuneSelf
+}
+
+// isSafeChar implements the RFC 2849 SAFE-CHAR rule,
+// which excludes NUL, LF and CR.
+func isSafeChar(c byte) bool {
+	switch c {
+	case 0x00, '\n', '\r':
+		return false
+	}
+
+	return c < utf8.RuneSelf
+}
+
+func isSafe(val string, allowUTF8 bool) bool {
+	if val == "" {
+		return true
+	}
+
+	if allowUTF8 && !utf8.ValidString(val) {
+		return false
+	}
+
+	first := val[0]
+	if !isSafeInitChar(first) && !(allowUTF8 && first >= utf8.RuneSelf) {
+		return false
+	}
+
+	for i := 1; i < len(val); i++ {
+		c := val[i]
+		if !isSafeChar(c) && !(allowUTF8 && c >= utf8.RuneSelf) {
+			return false
+		}
+	}
+
+	// RFC 2849 note 8: values ending with a space should be base64 encoded
+	return val[len(val)-1] != ' '
+}
+
+// IsSafeString returns true if the value can be written as a plain
+// LDIF value according to the RFC 2849 SAFE-STRING rules. Values
+// that are not safe must be base64 encoded.
+func IsSafeString(val string) bool {
+	return isSafe(val, false)
+}
+
+// IsSafeUTF8String relaxes IsSafeString to also accept valid
+// non-ASCII UTF-8 characters, which keeps human readable text
+// unencoded at the cost of strict RFC 2849 compliance.
+func IsSafeUTF8String(val string) bool {
+	return isSafe(val, true)
+}
+
+// IsContinuationLine returns true if the line continues the
+// previous line, as produced by RFC 2849 line folding.
+func IsContinuationLine(line string) bool {
+	return strings.HasPrefix(line, " ")
+}
+
+// FoldLine splits a line into RFC 2849 continuation lines that are
+// at most `width` bytes long, including the leading space of each
+// continuation. Lines are never split inside a multibyte UTF-8 sequence.
+// A width of zero or less disables folding.
+func FoldLine(line string, width int) []string {
+	// a continuation line needs room for the space and at least one byte
+	if width < 2 || len(line) <= width {
+		return []string{line}
+	}
+
+	folded := []string{}
+	contentStart := 0
+
+	for len(line) > width {
+		split := width
+		for split > contentStart && !utf8.RuneStart(line[split]) {
+			split--
+		}
+
+		// a single rune wider than the fold width can't be split
+		if split == contentStart {
+			_, runeLen := utf8.DecodeRuneInString(line[contentStart:])
+			split = contentStart + runeLen
+		}
+
+		folded = append(folded, line[:split])
+		line = " " + line[split:]
+		contentStart = 1
+	}
+
+	return append(folded, line)
+}
+
+// IsVersionLine returns true if the line is an
+// LDIF `version-spec`, ex) version: 1
+func IsVersionLine(line string) bool {
+	return strings.HasPrefix(line, "version:")
+}
+
+// IsSearchResultLine returns true if the line starts the
+// search result block that ldapsearch appends to its output.
+func IsSearchResultLine(line string) bool {
+	return strings.HasPrefix(line, "search:")
 }
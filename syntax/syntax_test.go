@@ -1,7 +1,10 @@
 package syntax_test
 
 import (
+	"encoding/base64"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/kgoins/ldifparser/syntax"
 	"github.com/stretchr/testify/require"
@@ -42,3 +45,245 @@ func TestSyntax_IsLdifAttrLine(t *testing.T) {
 		r.Equal(expectedResp, resp)
 	}
 }
+
+func TestSyntax_IsSafeString(t *testing.T) {
+	r := require.New(t)
+
+	testMap := map[string]bool{
+		"":                     true,
+		"MYUSR":                true,
+		"CN=MYUSR,DC=corp":     true,
+		"has inner spaces":     true,
+		" leading space":       false,
+		"trailing space ":      false,
+		":leading colon":       false,
+		"<leading less-than":   false,
+		"inner: colon":         true,
+		"line\nbreak":          false,
+		"carriage\rreturn":     false,
+		"nul\x00byte":          false,
+		"Jürgen":               false,
+		"\xff\xfe binary data": false,
+	}
+
+	for testVal, expectedResp := range testMap {
+		resp := syntax.IsSafeString(testVal)
+		r.Equal(expectedResp, resp, testVal)
+	}
+}
+
+func TestSyntax_IsSafeUTF8String(t *testing.T) {
+	r := require.New(t)
+
+	testMap := map[string]bool{
+		"MYUSR":                true,
+		"Jürgen":               true,
+		"ürgen":                true,
+		" Jürgen":              false,
+		"Jürgen\n":             false,
+		"\xff\xfe binary data": false,
+	}
+
+	for testVal, expectedResp := range testMap {
+		resp := syntax.IsSafeUTF8String(testVal)
+		r.Equal(expectedResp, resp, testVal)
+	}
+}
+
+func TestSyntax_FoldLine(t *testing.T) {
+	r := require.New(t)
+
+	line := "description: " + strings.Repeat("abcdefghij", 3)
+
+	r.Equal([]string{line}, syntax.FoldLine(line, 0))
+	r.Equal([]string{line}, syntax.FoldLine(line, len(line)))
+
+	folded := syntax.FoldLine(line, 20)
+	r.Equal([]string{
+		"description: abcdefg",
+		" hijabcdefghijabcdef",
+		" ghij",
+	}, folded)
+
+	for _, foldedLine := range folded {
+		r.LessOrEqual(len(foldedLine), 20)
+	}
+}
+
+func TestSyntax_FoldLine_MultibyteRunes(t *testing.T) {
+	r := require.New(t)
+
+	line := "sn: " + strings.Repeat("ü", 10)
+	folded := syntax.FoldLine(line, 7)
+
+	unfolded := folded[0]
+	for _, foldedLine := range folded {
+		r.True(utf8.ValidString(foldedLine), foldedLine)
+		r.LessOrEqual(len(foldedLine), 7)
+		r.True(syntax.IsContinuationLine(foldedLine) || foldedLine == folded[0])
+	}
+
+	for _, foldedLine := range folded[1:] {
+		unfolded += foldedLine[1:]
+	}
+
+	r.Equal(line, unfolded)
+}
+
+func TestSyntax_BuildTitle(t *testing.T) {
+	r := require.New(t)
+
+	testMap := map[string]string{
+		"CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com":       "MYUSR, ContosoUsers, contoso.com",
+		"cn=myusr,ou=users,dc=contoso,dc=com":              "myusr, users, contoso.com",
+		"CN=DISABLEDUSER\\, ME,OU=ContosoUsers,DC=contoso": "DISABLEDUSER\\, ME, ContosoUsers, contoso",
+		"CN=Doe\\2C John,OU=Users,DC=contoso,DC=com":       "Doe\\, John, Users, contoso.com",
+		"CN=back\\\\,OU=Users,DC=contoso,DC=com":           "back\\\\, Users, contoso.com",
+		"CN=a=b,O=example":                                 "a=b, example",
+		"cn=config":                                        "config",
+		"olcDatabase={1}mdb,cn=config":                     "{1}mdb, config",
+		"CN=MYUSR+UID=myusr,OU=Users,DC=contoso,DC=com":    "MYUSR + myusr, Users, contoso.com",
+		"DC=contoso,DC=com":                                "contoso.com",
+		"DC=ForestDnsZones,OU=Zones,DC=contoso,DC=com":     "ForestDnsZones, Zones, contoso.com",
+		"CN=\"Quoted, Value\",DC=com":                      "Quoted\\, Value, com",
+		"":                                                 "",
+	}
+
+	for dn, expected := range testMap {
+		title, err := syntax.BuildTitle(dn)
+		r.NoError(err, dn)
+		r.Equal(expected, title, dn)
+	}
+
+	_, err := syntax.BuildTitle("CN=MYUSR,malformed")
+	r.Error(err)
+}
+
+func TestSyntax_ParseDN(t *testing.T) {
+	r := require.New(t)
+
+	dn, err := syntax.ParseDN(`CN=Doe\, John+UID=jdoe,OU=Users\\,OU=a\2Cb,DC=contoso,DC=com`)
+	r.NoError(err)
+	r.Len(dn.RDNs, 5)
+
+	rdn := dn.RDN()
+	r.Len(rdn.AVAs, 2)
+	r.Equal(syntax.AVA{Type: "CN", Value: "Doe, John"}, rdn.AVAs[0])
+
+	uid, found := rdn.Get("uid")
+	r.True(found)
+	r.Equal("jdoe", uid)
+
+	r.Equal(`Users\`, dn.RDNs[1].AVAs[0].Value)
+	r.Equal("a,b", dn.RDNs[2].AVAs[0].Value)
+	r.Equal(`CN=Doe\, John+UID=jdoe,OU=Users\\,OU=a\,b,DC=contoso,DC=com`, dn.String())
+
+	utf8DN, err := syntax.ParseDN(`cn=J\C3\BCrgen`)
+	r.NoError(err)
+	r.Equal("Jürgen", utf8DN.RDN().AVAs[0].Value)
+
+	spacedDN, err := syntax.ParseDN(`cn = \ padded\ , ou="Quoted, Value" ; dc=com`)
+	r.NoError(err)
+	r.Equal(" padded ", spacedDN.RDN().AVAs[0].Value)
+	r.Equal("Quoted, Value", spacedDN.RDNs[1].AVAs[0].Value)
+	r.Equal(`cn=\ padded\ ,ou=Quoted\, Value,dc=com`, spacedDN.String())
+
+	root, err := syntax.ParseDN("")
+	r.NoError(err)
+	r.True(root.IsEmpty())
+
+	for _, malformed := range []string{"CN", "CN=a,", "=a", "CN=a\\", `CN="open`, "C N=a"} {
+		_, err := syntax.ParseDN(malformed)
+		r.Error(err, malformed)
+	}
+}
+
+func TestSyntax_DNComparison(t *testing.T) {
+	r := require.New(t)
+
+	user := syntax.MustParseDN("CN=MYUSR+UID=myusr,OU=Users,DC=contoso,DC=com")
+	sameUser := syntax.MustParseDN("uid=MYUSR+cn=myusr, ou=users, dc=CONTOSO, dc=com")
+	users := syntax.MustParseDN("ou=users,dc=contoso,dc=com")
+	domain := syntax.MustParseDN("DC=contoso,DC=com")
+	other := syntax.MustParseDN("DC=fabrikam,DC=com")
+
+	r.True(user.Equal(sameUser))
+	r.Equal(user.CanonicalString(), sameUser.CanonicalString())
+	r.Equal("cn=myusr+uid=myusr,ou=users,dc=contoso,dc=com", user.CanonicalString())
+
+	r.True(user.Parent().Equal(users))
+	r.True(user.IsDescendantOf(users))
+	r.True(user.IsDescendantOf(domain))
+	r.False(user.IsDescendantOf(other))
+	r.False(users.IsDescendantOf(users))
+	r.False(domain.IsDescendantOf(user))
+
+	r.True(domain.Parent().Parent().IsEmpty())
+	r.True(domain.Parent().Parent().Parent().IsEmpty())
+}
+
+func TestSyntax_GUID(t *testing.T) {
+	r := require.New(t)
+
+	guidBytes, err := base64.StdEncoding.DecodeString("7OBfD10nQkSVYY8UHCV2aQ==")
+	r.NoError(err)
+
+	for _, guid := range []string{
+		"0f5fe0ec-275d-4442-9561-8f141c257669",
+		"{0F5FE0EC-275D-4442-9561-8F141C257669}",
+	} {
+		parsed, err := syntax.ParseGUID(guid)
+		r.NoError(err)
+		r.Equal(guidBytes, parsed)
+	}
+
+	formatted, err := syntax.FormatGUID(guidBytes)
+	r.NoError(err)
+	r.Equal("0f5fe0ec-275d-4442-9561-8f141c257669", formatted)
+
+	for _, malformed := range []string{"", "0f5fe0ec-275d-4442-9561", "0f5fe0ec-275d-4442-9561-8f141c25766z", "0f5fe0ec275d-4-442-9561-8f141c257669"} {
+		_, err := syntax.ParseGUID(malformed)
+		r.Error(err, malformed)
+	}
+}
+
+func TestSyntax_SID(t *testing.T) {
+	r := require.New(t)
+
+	sidBytes, err := base64.StdEncoding.DecodeString("AQUAAAAAAAUVAAAAa9ZiBBbA6jKDPStVYiIMAA==")
+	r.NoError(err)
+
+	sid := "S-1-5-21-73586283-854245398-1428897155-795234"
+
+	parsed, err := syntax.ParseSID(sid)
+	r.NoError(err)
+	r.Equal(sidBytes, parsed)
+
+	formatted, err := syntax.FormatSID(sidBytes)
+	r.NoError(err)
+	r.Equal(sid, formatted)
+
+	for _, wellKnown := range []string{"S-1-5-32-544", "S-1-1-0", "S-1-5-18", "S-1-0x123456789ABC-7"} {
+		parsed, err := syntax.ParseSID(wellKnown)
+		r.NoError(err)
+
+		formatted, err := syntax.FormatSID(parsed)
+		r.NoError(err)
+		r.Equal(wellKnown, formatted)
+	}
+
+	for _, malformed := range []string{
+		"", "S-1", "X-1-5-21", "S-1-5-21-4294967296", "S-x-5-21",
+		"S-1-0b101-21", "S-1-0o17-21", "S-1-1_0-21", "S-1-0x-21", "S-1-+5-21",
+	} {
+		_, err := syntax.ParseSID(malformed)
+		r.Error(err, malformed)
+	}
+
+	// authorities with leading zeros are decimal, not octal
+	leadingZero, err := syntax.ParseSID("S-1-010-21")
+	r.NoError(err)
+	decimal, err := syntax.ParseSID("S-1-10-21")
+	r.NoError(err)
+	r.Equal(decimal, leadingZero)
+}
@@ -2,82 +2,64 @@ package syntax
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/kgoins/ldapentity/entity"
 )
 
-func buildTitleDomain(domainParts []string) (string, error) {
-	domains := []string{}
-	for _, domainPart := range domainParts {
-		domainSplit := strings.Split(domainPart, "=")
-		if len(domainSplit) < 2 {
-			return "", errors.New("malformed domain")
-		}
+// isDomainComponent returns true for single-valued `dc` RDNs
+func isDomainComponent(rdn RDN) bool {
+	return len(rdn.AVAs) == 1 && strings.EqualFold(rdn.AVAs[0].Type, "dc")
+}
 
-		domain := domainSplit[1]
-		domains = append(domains, domain)
+// BuildTitle returns the user friendly form of a DN that ldapsearch writes
+// as the title comment of each entity, ex) `CN=MYUSR,OU=Users,DC=contoso,DC=com`
+// becomes `MYUSR, Users, contoso.com`. Trailing domain components are joined
+// with dots and the values of multi-valued RDNs are joined with ` + `.
+func BuildTitle(dn string) (string, error) {
+	parsedDN, err := ParseDN(dn)
+	if err != nil {
+		return "", err
 	}
+	components := parsedDN.RDNs
 
-	return strings.Join(domains, "."), nil
-}
+	domainStart := len(components)
+	for domainStart > 0 && isDomainComponent(components[domainStart-1]) {
+		domainStart--
+	}
 
-func buildTitleObj(objParts []string) (string, error) {
-	objComponents := []string{}
-	for _, objPart := range objParts {
-		objSplit := strings.Split(objPart, "=")
-		if len(objSplit) < 2 {
-			return "", errors.New("malformed object")
+	titleParts := []string{}
+	for _, component := range components[:domainStart] {
+		values := []string{}
+		for _, ava := range component.AVAs {
+			values = append(values, EscapeDNValue(ava.Value))
 		}
-
-		objComponent := objSplit[1]
-		objComponents = append(objComponents, objComponent)
+		titleParts = append(titleParts, strings.Join(values, " + "))
 	}
 
-	return strings.Join(objComponents, ", "), nil
-}
-
-func splitDN(dnStr string) []string {
-	escSeq := "++"
-	dnClean := strings.ReplaceAll(dnStr, `\,`, escSeq)
-	dnParts := strings.Split(dnClean, ",")
+	domainParts := []string{}
+	for _, component := range components[domainStart:] {
+		domainParts = append(domainParts, EscapeDNValue(component.AVAs[0].Value))
+	}
 
-	for i := range dnParts {
-		dnParts[i] = strings.ReplaceAll(dnParts[i], escSeq, `\,`)
+	if len(domainParts) > 0 {
+		titleParts = append(titleParts, strings.Join(domainParts, "."))
 	}
 
-	return dnParts
+	return strings.Join(titleParts, ", "), nil
 }
 
+// BuildTitleLine returns the ldapsearch title comment for an entity.
 func BuildTitleLine(entity entity.Entity) (string, error) {
 	dn, dnFound := entity.GetDN()
 	if !dnFound {
 		return "", errors.New("unable to find DN in entity")
 	}
 
-	dnParts := splitDN(dn)
-
-	domainParts := []string{}
-	objParts := []string{}
-
-	for _, part := range dnParts {
-		if strings.HasPrefix(part, "DC=") {
-			domainParts = append(domainParts, part)
-		} else {
-			objParts = append(objParts, part)
-		}
-	}
-
-	domain, err := buildTitleDomain(domainParts)
-	if err != nil {
-		return "", err
-	}
-
-	obj, err := buildTitleObj(objParts)
+	title, err := BuildTitle(dn)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("# %s, %s", obj, domain), nil
+	return strings.TrimRight("# "+title, " "), nil
 }
@@ -1,20 +1,37 @@
 package ldifparser
 
 import (
+	"bufio"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/kgoins/ldapentity/entity"
+	"github.com/kgoins/ldifparser/entitybuilder"
 	"github.com/kgoins/ldifparser/syntax"
 )
 
+// LdifWriter serializes LDAP Entities to LDIF format. Output is
+// buffered, so Flush or Close must be called once writing is done.
+// The first error returned by the underlying io.Writer is retained
+// and returned by every subsequent write call.
 type LdifWriter struct {
-	output io.Writer
+	dest   io.Writer
+	output *bufio.Writer
+	err    error
+
+	entitiesWritten int
+	bytesWritten    int64
+
 	WriterConf
 }
 
-func NewLdifWriter(o io.Writer, conf ...WriterConf) LdifWriter {
+// NewLdifWriter returns a constructed LdifWriter that
+// buffers its writes to the io.Writer `o`.
+func NewLdifWriter(o io.Writer, conf ...WriterConf) *LdifWriter {
 	var actualConf WriterConf
 	if len(conf) == 0 {
 		actualConf = NewWriterConf()
@@ -22,43 +39,195 @@ func NewLdifWriter(o io.Writer, conf ...WriterConf) LdifWriter {
 		actualConf = conf[0]
 	}
 
-	return LdifWriter{
-		output:     o,
+	bufSize := actualConf.BufferSize
+	if bufSize <= 0 {
+		bufSize = DefaultWriterBufferSize
+	}
+
+	return &LdifWriter{
+		dest:       o,
+		output:     bufio.NewWriterSize(o, bufSize),
 		WriterConf: actualConf,
 	}
 }
 
-func StringifyAttribute(attr entity.Attribute) []string {
+func stringifyValue(name string, value string, allowUTF8 bool) string {
+	isSafe := syntax.IsSafeString(value)
+	if allowUTF8 {
+		isSafe = syntax.IsSafeUTF8String(value)
+	}
+
+	if !isSafe {
+		encoded := base64.StdEncoding.EncodeToString([]byte(value))
+		return fmt.Sprintf("%s:: %s", name, encoded)
+	}
+
+	return fmt.Sprintf("%s: %s", name, value)
+}
+
+func stringifyAttribute(attr entity.Attribute, allowUTF8 bool, sortValues bool) []string {
 	vals := make([]string, 0, attr.Value.Size())
 
-	for _, value := range attr.Value.Values() {
-		vals = append(vals, fmt.Sprintf("%s: %s", attr.Name, value))
+	values := attr.Value.Values()
+	if sortValues {
+		sort.Strings(values)
+	}
+
+	for _, value := range values {
+		vals = append(vals, stringifyValue(attr.Name, value, allowUTF8))
 	}
 
 	return vals
 }
 
-func (w LdifWriter) writeAttribute(attr entity.Attribute) {
-	for _, line := range StringifyAttribute(attr) {
-		fmt.Fprint(w.output, line+"\n")
+// StringifyAttribute returns one LDIF line per attribute value.
+// Values that are not an RFC 2849 SAFE-STRING are base64 encoded
+// and written in `name:: value` format.
+func StringifyAttribute(attr entity.Attribute) []string {
+	return stringifyAttribute(attr, false, false)
+}
+
+// write sends `s` to the output buffer unless a previous write
+// has failed, in which case the original error is kept.
+func (w *LdifWriter) write(s string) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := w.output.WriteString(s)
+	w.bytesWritten += int64(n)
+	w.err = err
+}
+
+// writeBytes is the []byte form of write
+func (w *LdifWriter) writeBytes(b []byte) {
+	if w.err != nil {
+		return
 	}
+
+	n, err := w.output.Write(b)
+	w.bytesWritten += int64(n)
+	w.err = err
 }
 
-// WriteEntity will serialize an Entity to LDIF format and write
-// it to the configured io.Writer. Attributes will be printed alphabetically.
-func (w LdifWriter) WriteEntity(e entity.Entity) (err error) {
+// writeLine folds the line at the configured width
+// and writes it to the output.
+func (w *LdifWriter) writeLine(line string) {
+	for _, foldedLine := range syntax.FoldLine(line, w.FoldWidth) {
+		w.write(foldedLine + "\n")
+	}
+}
+
+func (w *LdifWriter) writeAttribute(attr entity.Attribute) {
+	for _, line := range stringifyAttribute(attr, w.PreserveUTF8, w.SortValues) {
+		w.writeLine(line)
+	}
+}
+
+// Err returns the first error encountered while writing, if any.
+func (w *LdifWriter) Err() error {
+	return w.err
+}
+
+// EntitiesWritten returns the number of entities successfully
+// written to the output buffer.
+func (w *LdifWriter) EntitiesWritten() int {
+	return w.entitiesWritten
+}
+
+// BytesWritten returns the number of bytes successfully
+// written to the output buffer.
+func (w *LdifWriter) BytesWritten() int64 {
+	return w.bytesWritten
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *LdifWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.err = w.output.Flush()
+	return w.err
+}
+
+// Close flushes any buffered data and closes the underlying
+// io.Writer if it implements io.Closer.
+func (w *LdifWriter) Close() error {
+	err := w.Flush()
+
+	closer, isCloser := w.dest.(io.Closer)
+	if !isCloser {
+		return err
+	}
+
+	closeErr := closer.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// buildTitleLine formats the title comment with the configured
+// TitleFormatter, making sure the result is an LDIF comment.
+func (w *LdifWriter) buildTitleLine(e entity.Entity) (string, error) {
+	formatter := w.TitleFormatter
+	if formatter == nil {
+		formatter = syntax.BuildTitleLine
+	}
 
-	titleLine, err := syntax.BuildTitleLine(e)
+	titleLine, err := formatter(e)
 	if err != nil {
-		return
+		return "", err
+	}
+
+	if !syntax.IsLdifComment(titleLine) {
+		titleLine = "# " + titleLine
+	}
+
+	return titleLine, nil
+}
+
+// attributeOrder returns the configured AttributeOrder. Without one,
+// attributes are sorted alphabetically so that output is deterministic.
+func (w *LdifWriter) attributeOrder() AttributeOrder {
+	if w.AttributeOrder != nil {
+		return w.AttributeOrder
+	}
+
+	return AlphabeticalOrder
+}
+
+// WriteEntity will serialize an Entity to LDIF format and write it to
+// the configured io.Writer. The DN is written first, followed by the
+// remaining attributes in the order set by the configured AttributeOrder.
+func (w *LdifWriter) WriteEntity(e entity.Entity) (err error) {
+	if w.err != nil {
+		return w.err
+	}
+
+	if !w.OmitTitles {
+		titleLine, titleErr := w.buildTitleLine(e)
+		if titleErr != nil {
+			return titleErr
+		}
+
+		w.writeLine(titleLine)
 	}
 
-	fmt.Fprint(w.output, titleLine+"\n")
+	dnAttr, found := e.GetAttribute("dn")
+	if found {
+		w.writeAttribute(dnAttr)
+	}
 
-	attrNames := e.GetAllAttributeNames()
-	if w.SortAttributes {
-		sort.Strings(attrNames)
+	attrNames := make([]string, 0, e.Size())
+	for _, name := range e.GetAllAttributeNames() {
+		if name != "dn" {
+			attrNames = append(attrNames, name)
+		}
 	}
+	w.attributeOrder()(attrNames)
 
 	for _, name := range attrNames {
 		attr, found := e.GetAttribute(name)
@@ -67,6 +236,88 @@ func (w LdifWriter) WriteEntity(e entity.Entity) (err error) {
 		}
 	}
 
-	w.output.Write([]byte{'\n'})
+	w.write("\n")
+	if w.err != nil {
+		return w.err
+	}
+
+	w.entitiesWritten++
 	return
 }
+
+// WriteRecord writes a Record as it was read: comments, attribute order,
+// value order, name casing and value encodings are all kept. Values that
+// still match their Source are written with their source lines, so records
+// read by ReadRecordsChanneled are written back unchanged, apart from folded
+// comments and CRLF line endings. Other values are folded at FoldWidth and
+// their separator is followed by a single space.
+func (w *LdifWriter) WriteRecord(rec entitybuilder.Record) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if _, found := rec.GetDN(); !found {
+		return errors.New("unable to find DN in record")
+	}
+
+	for _, comment := range rec.Comments {
+		w.writeLine(comment)
+	}
+
+	for _, attr := range rec.Attributes {
+		for _, comment := range attr.Comments {
+			w.writeLine(comment)
+		}
+
+		for _, val := range attr.Values {
+			if source, isUnchanged := val.SourceLines(attr.Name); isUnchanged {
+				w.write(source + "\n")
+				continue
+			}
+
+			line := attr.Name + val.Encoding.Separator() + val.Value
+			if val.Value == "" {
+				line = strings.TrimRight(line, " ")
+			}
+			w.writeLine(line)
+		}
+	}
+
+	for _, comment := range rec.TrailingComments {
+		w.writeLine(comment)
+	}
+
+	w.write("\n")
+	if w.err != nil {
+		return w.err
+	}
+
+	w.entitiesWritten++
+	return nil
+}
+
+// WriteRaw writes the text of a RawBlock unchanged, followed by the blank
+// line that separates records. None of the writer's formatting options
+// are applied.
+func (w *LdifWriter) WriteRaw(block RawBlock) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if len(block.Data) == 0 {
+		return errors.New("unable to write an empty raw block")
+	}
+
+	w.writeBytes(block.Data)
+	if block.Data[len(block.Data)-1] != '\n' {
+		w.write("\n")
+	}
+
+	w.write("\n")
+	if w.err != nil {
+		return w.err
+	}
+
+	w.entitiesWritten++
+	return nil
+}
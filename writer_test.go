@@ -1,10 +1,13 @@
 package ldifparser_test
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kgoins/ldapentity/entity"
 	"github.com/kgoins/ldifparser"
@@ -42,6 +45,107 @@ func TestWriter_StringifyAttribute_MultiValue(t *testing.T) {
 	r.Len(attrStr, 3)
 }
 
+func TestWriter_StringifyAttribute_UnsafeValues(t *testing.T) {
+	r := require.New(t)
+
+	testMap := map[string]string{
+		"MYUSR":           "description: MYUSR",
+		" leading space":  "description:: IGxlYWRpbmcgc3BhY2U=",
+		":colon":          "description:: OmNvbG9u",
+		"two\nlines":      "description:: dHdvCmxpbmVz",
+		"trailing space ": "description:: dHJhaWxpbmcgc3BhY2Ug",
+		"Jürgen":          "description:: SsO8cmdlbg==",
+	}
+
+	for value, expectedLine := range testMap {
+		attr := entity.NewEntityAttribute("description", value)
+		attrStr := ldifparser.StringifyAttribute(attr)
+		r.Equal([]string{expectedLine}, attrStr)
+	}
+}
+
+func TestWriter_UnsafeValuesRoundTrip(t *testing.T) {
+	r := require.New(t)
+
+	values := []string{
+		" lead",
+		"trail ",
+		":colon",
+		"<angle",
+		"two\nlines",
+		"Jürgen",
+		"\x00\xff binary",
+	}
+
+	e := entity.NewEntity("CN=MYUSR,OU=ContosoUsers,DC=contoso,DC=com")
+	e.AddAttribute(entity.NewEntityAttribute("cn", "MYUSR"))
+	e.AddAttribute(entity.NewEntityAttribute("description", values...))
+
+	var buf bytes.Buffer
+	writer := ldifparser.NewLdifWriter(&buf)
+	r.NoError(writer.WriteEntity(e))
+	r.NoError(writer.Flush())
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(buf.Bytes()))
+	readBack, err := ldifReader.ReadEntity("cn", "MYUSR")
+	r.NoError(err)
+
+	attr, found := readBack.GetAttribute("description")
+	r.True(found)
+	r.ElementsMatch(values, attr.GetValues())
+}
+
+func TestWriter_WriteEntity_PreserveUTF8(t *testing.T) {
+	r := require.New(t)
+
+	e := buildTestEntity()
+	e.AddAttribute(entity.NewEntityAttribute("displayName", "Jürgen"))
+	e.AddAttribute(entity.NewEntityAttribute("description", "line\nbreak"))
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+	r.NoError(writer.WriteEntity(e))
+	r.NoError(writer.Flush())
+	r.Contains(outBuffer.String(), "displayName:: SsO8cmdlbg==\n")
+
+	conf := ldifparser.NewWriterConf()
+	conf.PreserveUTF8 = true
+
+	outBuffer.Reset()
+	writer = ldifparser.NewLdifWriter(&outBuffer, conf)
+	r.NoError(writer.WriteEntity(e))
+	r.NoError(writer.Flush())
+	r.Contains(outBuffer.String(), "displayName: Jürgen\n")
+	r.Contains(outBuffer.String(), "description:: bGluZQpicmVhaw==\n")
+}
+
+func TestWriter_WriteEntity_FoldWidth(t *testing.T) {
+	r := require.New(t)
+
+	longVal := strings.Repeat("Jürgen ", 30)
+	e := buildTestEntity()
+	e.AddAttribute(entity.NewEntityAttribute("description", strings.TrimSpace(longVal)))
+
+	conf := ldifparser.NewWriterConf()
+	conf.FoldWidth = 76
+	conf.PreserveUTF8 = true
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer, conf)
+	r.NoError(writer.WriteEntity(e))
+	r.NoError(writer.Flush())
+
+	outStr := outBuffer.String()
+	for _, line := range strings.Split(outStr, "\n") {
+		r.LessOrEqual(len(line), 76)
+	}
+
+	reader := ldifparser.NewLdifReader(strings.NewReader(outStr))
+	eOut, err := reader.ReadEntity("sAMAccountName", "MYUSR")
+	r.NoError(err)
+	r.True(e.Equals(eOut))
+}
+
 func TestWriter_WriteEntity(t *testing.T) {
 	r := require.New(t)
 
@@ -52,6 +156,7 @@ func TestWriter_WriteEntity(t *testing.T) {
 
 	err := writer.WriteEntity(e)
 	r.NoError(err)
+	r.NoError(writer.Flush())
 
 	outStr := outBuffer.String()
 	inBuffer := strings.NewReader(outStr)
@@ -90,8 +195,305 @@ func TestReaderToWriter(t *testing.T) {
 		err = writer.WriteEntity(resp.Entity)
 		r.NoError(err)
 	}
+	r.NoError(writer.Flush())
 
 	info, err := os.Stat(outFilePath)
 	r.NoError(err)
-	r.Equal(int64(3776), info.Size())
+	r.Equal(int64(3782), info.Size())
+}
+
+type failingWriter struct {
+	limit int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errDiskFull
+	}
+
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriter_WriteErrorIsRetained(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewWriterConf()
+	conf.BufferSize = 16
+	writer := ldifparser.NewLdifWriter(&failingWriter{limit: 64}, conf)
+
+	e := buildTestEntity()
+	err := writer.WriteEntity(e)
+	r.ErrorIs(err, errDiskFull)
+
+	r.ErrorIs(writer.WriteEntity(e), errDiskFull)
+	r.ErrorIs(writer.Flush(), errDiskFull)
+	r.ErrorIs(writer.Close(), errDiskFull)
+	r.ErrorIs(writer.Err(), errDiskFull)
+	r.Equal(0, writer.EntitiesWritten())
+}
+
+func TestWriter_Counts(t *testing.T) {
+	r := require.New(t)
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	for i := 0; i < 3; i++ {
+		r.NoError(writer.WriteEntity(buildTestEntity()))
+	}
+	r.Empty(outBuffer.String())
+	r.NoError(writer.Close())
+
+	r.Equal(3, writer.EntitiesWritten())
+	r.Equal(int64(outBuffer.Len()), writer.BytesWritten())
+}
+
+func TestWriter_WriteHeaderAndTrailer(t *testing.T) {
+	r := require.New(t)
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	header := ldifparser.Header{
+		Version:    true,
+		Base:       "DC=contoso,DC=com",
+		Filter:     "(objectClass=user)",
+		Attributes: []string{"cn", "sAMAccountName"},
+		Generator:  "ldifparser",
+		Timestamp:  time.Date(2021, 6, 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	r.NoError(writer.WriteHeader(header))
+	r.NoError(writer.WriteEntity(buildTestEntity()))
+	r.NoError(writer.WriteTrailer())
+	r.NoError(writer.Flush())
+
+	outStr := outBuffer.String()
+	r.True(strings.HasPrefix(outStr, strings.Join([]string{
+		"version: 1",
+		"",
+		"# extended LDIF",
+		"#",
+		"# LDAPv3",
+		"# base <DC=contoso,DC=com> with scope subtree",
+		"# filter: (objectClass=user)",
+		"# requesting: cn sAMAccountName",
+		"# generator: ldifparser",
+		"# timestamp: 20210601123000Z",
+		"#",
+		"",
+		"# MYUSR, ContosoUsers, contoso.com",
+	}, "\n")))
+	r.True(strings.HasSuffix(outStr, "# numResponses: 2\n# numEntries: 1\n"))
+
+	reader := ldifparser.NewLdifReader(strings.NewReader(outStr))
+	entities := reader.ReadEntities()
+	r.Len(entities, 1)
+	r.NoError(entities[0].Error)
+	r.True(buildTestEntity().Equals(entities[0].Entity))
+}
+
+func getWrittenAttrNames(t *testing.T, e entity.Entity, conf ldifparser.WriterConf) []string {
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer, conf)
+	require.NoError(t, writer.WriteEntity(e))
+	require.NoError(t, writer.Flush())
+
+	names := []string{}
+	for _, line := range strings.Split(outBuffer.String(), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name := strings.SplitN(line, ":", 2)[0]
+		if len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func TestWriter_AttributeOrder(t *testing.T) {
+	r := require.New(t)
+	e := buildTestEntity()
+
+	testMap := map[string]ldifparser.AttributeOrder{
+		"dn cn objectClass sAMAccountName whenCreated": ldifparser.AlphabeticalOrder,
+		"dn objectClass cn sAMAccountName whenCreated": ldifparser.ObjectClassFirstOrder,
+		"dn sAMAccountName cn objectClass whenCreated": ldifparser.SchemaOrder("samaccountname", "cn"),
+		"dn whenCreated sAMAccountName objectClass cn": ldifparser.ComparatorOrder(func(a, b string) bool {
+			return a > b
+		}),
+	}
+
+	for expected, order := range testMap {
+		conf := ldifparser.NewWriterConf()
+		conf.AttributeOrder = order
+
+		names := getWrittenAttrNames(t, e, conf)
+		r.Equal(expected, strings.Join(names, " "))
+	}
+
+	// the default order is deterministic
+	for i := 0; i < 5; i++ {
+		names := getWrittenAttrNames(t, e, ldifparser.NewWriterConf())
+		r.Equal("dn cn objectClass sAMAccountName whenCreated", strings.Join(names, " "))
+	}
+}
+
+func TestWriter_SortValues(t *testing.T) {
+	r := require.New(t)
+
+	conf := ldifparser.NewWriterConf()
+	conf.AttributeOrder = ldifparser.AlphabeticalOrder
+	conf.SortValues = true
+
+	var first string
+	for i := 0; i < 5; i++ {
+		var outBuffer strings.Builder
+		writer := ldifparser.NewLdifWriter(&outBuffer, conf)
+		r.NoError(writer.WriteEntity(buildTestEntity()))
+		r.NoError(writer.Flush())
+
+		if i == 0 {
+			first = outBuffer.String()
+			r.Contains(first, "objectClass: person\nobjectClass: top\nobjectClass: user\n")
+		}
+		r.Equal(first, outBuffer.String())
+	}
+}
+
+func TestWriter_WriteRecord_RoundTrip(t *testing.T) {
+	r := require.New(t)
+
+	testFilePath := filepath.Join(getTestDataDir(), testFileName)
+	testBytes, err := os.ReadFile(testFilePath)
+	r.NoError(err)
+
+	ldifReader := ldifparser.NewLdifReader(bytes.NewReader(testBytes))
+
+	done := make(chan bool)
+	defer close(done)
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer)
+
+	for resp := range ldifReader.ReadRecordsChanneled(done) {
+		r.NoError(resp.Error)
+		r.NoError(writer.WriteRecord(resp.Record))
+	}
+	r.NoError(writer.Flush())
+	r.Equal(numTestFileEntities, writer.EntitiesWritten())
+
+	testStr := string(testBytes)
+	firstEntity := strings.Index(testStr, "# MYUSR")
+	r.Equal(testStr[firstEntity:], outBuffer.String())
+}
+
+func TestWriter_WriteRecord_CommentsAndFolds(t *testing.T) {
+	r := require.New(t)
+
+	// folded at 76 columns, as ldapsearch does
+	longDescription := strings.Repeat("a long description ", 6)
+	testLdif := strings.Join([]string{
+		"# MYUSR, ContosoUsers, contoso.com",
+		"dn: CN=MYUSR,OU=ContosoUsers,DC=contoso",
+		" ,DC=com",
+		"objectClass: top",
+		"# classes continue below",
+		"objectClass: person",
+		"cn:  MYUSR",
+		"description: " + longDescription[:63],
+		" " + longDescription[63:],
+		"displayName:   three spaces",
+		"# end of MYUSR",
+		"",
+		"",
+	}, "\n")
+
+	ldifReader := ldifparser.NewLdifReader(strings.NewReader(testLdif))
+
+	done := make(chan bool)
+	defer close(done)
+
+	records := []entitybuilder.Record{}
+	for resp := range ldifReader.ReadRecordsChanneled(done) {
+		r.NoError(resp.Error)
+		records = append(records, resp.Record)
+	}
+	r.Len(records, 1)
+
+	writeRecord := func(rec entitybuilder.Record) string {
+		var outBuffer strings.Builder
+		writer := ldifparser.NewLdifWriter(&outBuffer)
+		r.NoError(writer.WriteRecord(rec))
+		r.NoError(writer.Flush())
+		return outBuffer.String()
+	}
+
+	r.Equal(testLdif, writeRecord(records[0]))
+
+	// changed values are written in the writer's own format
+	rec := records[0]
+	displayName := rec.Attributes[len(rec.Attributes)-1]
+	r.Equal("displayName", displayName.Name)
+	displayName.Values = []entitybuilder.RecordValue{{Value: "changed"}}
+	rec.Attributes = append(rec.Attributes[:len(rec.Attributes)-1:len(rec.Attributes)-1], displayName)
+
+	out := writeRecord(rec)
+	r.Contains(out, "cn:  MYUSR\n")
+	r.Contains(out, "displayName: changed\n")
+	r.NotContains(out, "three spaces")
+}
+
+func TestWriter_Titles(t *testing.T) {
+	r := require.New(t)
+
+	e := entity.NewEntity("o=example")
+	e.AddAttribute(entity.NewEntityAttribute("o", "example"))
+
+	conf := ldifparser.NewWriterConf()
+	conf.AttributeOrder = ldifparser.AlphabeticalOrder
+
+	testMap := map[string]func(*ldifparser.WriterConf){
+		"# example\ndn: o=example\no: example\n\n": func(c *ldifparser.WriterConf) {},
+		"dn: o=example\no: example\n\n": func(c *ldifparser.WriterConf) {
+			c.OmitTitles = true
+		},
+		"# custom o=example\ndn: o=example\no: example\n\n": func(c *ldifparser.WriterConf) {
+			c.TitleFormatter = func(e entity.Entity) (string, error) {
+				dn, _ := e.GetDN()
+				return "custom " + dn, nil
+			}
+		},
+	}
+
+	for expected, modifyConf := range testMap {
+		testConf := conf
+		modifyConf(&testConf)
+
+		var outBuffer strings.Builder
+		writer := ldifparser.NewLdifWriter(&outBuffer, testConf)
+		r.NoError(writer.WriteEntity(e))
+		r.NoError(writer.Flush())
+		r.Equal(expected, outBuffer.String())
+	}
+}
+
+func TestWriter_TitlesWithZeroConf(t *testing.T) {
+	r := require.New(t)
+
+	e := entity.NewEntity("o=example")
+
+	var outBuffer strings.Builder
+	writer := ldifparser.NewLdifWriter(&outBuffer, ldifparser.WriterConf{})
+	r.NoError(writer.WriteEntity(e))
+	r.NoError(writer.Flush())
+	r.Equal("# example\ndn: o=example\n\n", outBuffer.String())
 }